@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"queuectl.backend/internal/config"
+	"queuectl.backend/internal/job"
+)
+
+const (
+	// csrfCookie holds the token that dashboard forms must send back in
+	// csrfField (or scripts in the X-CSRF-Token header).
+	csrfCookie = "queuectl_csrf"
+	csrfField  = "csrf_token"
+	// noticeCookie carries the outcome of an action to the page it
+	// redirects to.
+	noticeCookie = "queuectl_notice"
+)
+
+// dashboardAction is something the dashboard can do to a job, one row at a
+// time or to every selected row.
+type dashboardAction struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	// Confirm is asked before acting; %s stands for the job or the number
+	// of jobs. Empty for actions that ask for a value instead.
+	Confirm string `json:"confirm,omitempty"`
+	done    string
+	// role is the role a user needs to take the action.
+	role config.Role
+	// applies reports whether the row of j offers the action.
+	applies func(j *job.Job) bool
+	run     func(id string, priority int) error
+}
+
+var dashboardActions = []*dashboardAction{
+	{
+		Name: "retry", Label: "Retry", Confirm: "Move %s from the DLQ back to pending?", done: "Retried", role: config.RoleOperator,
+		applies: func(j *job.Job) bool { return j.State == job.StateDead },
+		run: func(id string, _ int) error {
+			_, err := repo.RetryDead(id)
+			return err
+		},
+	},
+	{
+		Name: "run", Label: "Run now", Confirm: "Run %s now instead of waiting?", done: "Made runnable", role: config.RoleOperator,
+		applies: func(j *job.Job) bool {
+			return (j.State == job.StatePending || j.State == job.StateFailed) && j.RunAt != nil && j.RunAt.After(time.Now())
+		},
+		run: func(id string, _ int) error {
+			_, err := repo.RunNow(id)
+			return err
+		},
+	},
+	{
+		Name: "priority", Label: "Priority", done: "Reprioritized", role: config.RoleOperator,
+		applies: func(j *job.Job) bool {
+			return j.State == job.StatePending || j.State == job.StateFailed || j.State == job.StateBlocked
+		},
+		run: func(id string, priority int) error {
+			_, err := repo.SetPriority(id, priority)
+			return err
+		},
+	},
+	{
+		Name: "cancel", Label: "Cancel", Confirm: "Cancel %s? Running jobs are killed.", done: "Cancelled", role: config.RoleOperator,
+		applies: func(j *job.Job) bool { return !j.State.Finished() },
+		run: func(id string, _ int) error {
+			_, err := repo.Cancel(id)
+			return err
+		},
+	},
+	{
+		Name: "delete", Label: "Delete", Confirm: "Delete %s? This cannot be undone from the dashboard.", done: "Deleted", role: config.RoleAdmin,
+		applies: func(j *job.Job) bool { return j.State != job.StateProcessing },
+		run:     func(id string, _ int) error { return repo.Delete(id) },
+	},
+}
+
+// rowActions returns the actions the dashboard offers for j.
+func rowActions(j *job.Job) []*dashboardAction {
+	var actions []*dashboardAction
+	for _, a := range dashboardActions {
+		if a.applies(j) {
+			actions = append(actions, a)
+		}
+	}
+	return actions
+}
+
+// allowedActions returns the actions a user with role may take.
+func allowedActions(role config.Role, actions []*dashboardAction) []*dashboardAction {
+	var allowed []*dashboardAction
+	for _, a := range actions {
+		if role >= a.role {
+			allowed = append(allowed, a)
+		}
+	}
+	return allowed
+}
+
+func rowActionNames(j *job.Job) []string {
+	names := []string{}
+	for _, a := range rowActions(j) {
+		names = append(names, a.Name)
+	}
+	return names
+}
+
+// serveAction runs a dashboard action on the jobs posted in "id", or on
+// the single job in "only" when a row's own button was pressed, and
+// redirects back to "return" with a notice of the outcome.
+func serveAction(w http.ResponseWriter, r *http.Request) {
+	var action *dashboardAction
+	for _, a := range dashboardActions {
+		if a.Name == r.PathValue("action") {
+			action = a
+		}
+	}
+	if action == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if requestRole(r) < action.role {
+		http.Error(w, "this needs the "+action.role.String()+" role", http.StatusForbidden)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validCSRF(r) {
+		http.Error(w, "Invalid or missing CSRF token; reload the page and try again.", http.StatusForbidden)
+		return
+	}
+
+	ids := r.PostForm["id"]
+	if only := r.PostFormValue("only"); only != "" {
+		ids = []string{only}
+	}
+	priority := 0
+	if action.Name == "priority" {
+		p, err := strconv.Atoi(strings.TrimSpace(r.FormValue("priority")))
+		if err != nil {
+			redirectWithNotice(w, r, "Priority must be a whole number.")
+			return
+		}
+		priority = p
+	}
+	if len(ids) == 0 {
+		redirectWithNotice(w, r, "No jobs selected.")
+		return
+	}
+
+	var failures []string
+	for _, id := range ids {
+		if err := action.run(id, priority); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	notice := fmt.Sprintf("%s %d of %d job(s).", action.done, len(ids)-len(failures), len(ids))
+	if len(failures) > 0 {
+		notice += " " + strings.Join(failures, "; ") + "."
+	}
+	redirectWithNotice(w, r, notice)
+}
+
+// redirectWithNotice sends the browser back to the page named by the
+// "return" field, which must be a path on this server.
+func redirectWithNotice(w http.ResponseWriter, r *http.Request, notice string) {
+	target := r.FormValue("return")
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		target = "/"
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     noticeCookie,
+		Value:    url.QueryEscape(notice),
+		Path:     "/",
+		MaxAge:   60,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// takeNotice returns the notice left by the last action and clears it.
+func takeNotice(w http.ResponseWriter, r *http.Request) string {
+	c, err := r.Cookie(noticeCookie)
+	if err != nil {
+		return ""
+	}
+	http.SetCookie(w, &http.Cookie{Name: noticeCookie, Path: "/", MaxAge: -1})
+	notice, _ := url.QueryUnescape(c.Value)
+	return notice
+}
+
+// csrfToken returns the browser's CSRF token, issuing one if it has none.
+// Forms echo it back; another site can make the browser send the cookie
+// but cannot read it to fill in the form.
+func csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(csrfCookie); err == nil && len(c.Value) == 64 {
+		return c.Value
+	}
+	b := make([]byte, 32)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token
+}
+
+// validCSRF reports whether a POST carries the token of its cookie.
+func validCSRF(r *http.Request) bool {
+	c, err := r.Cookie(csrfCookie)
+	if err != nil || c.Value == "" {
+		return false
+	}
+	sent := r.Header.Get("X-CSRF-Token")
+	if sent == "" {
+		sent = r.PostFormValue(csrfField)
+	}
+	return subtle.ConstantTimeCompare([]byte(sent), []byte(c.Value)) == 1
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"queuectl.backend/internal/job"
+)
+
+// postAction posts form to a dashboard action, with csrfCookieValue as the
+// CSRF cookie unless it is empty.
+func postAction(action string, form url.Values, csrfCookieValue string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /actions/{action}", serveAction)
+	req := httptest.NewRequest("POST", "/actions/"+action, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if csrfCookieValue != "" {
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: csrfCookieValue})
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestActionsNeedCSRFToken(t *testing.T) {
+	useMemoryStore(t)
+	if err := repo.Create(&job.Job{ID: "a", Command: "true"}); err != nil {
+		t.Fatal(err)
+	}
+	token := strings.Repeat("ab", 32)
+
+	for name, tc := range map[string]struct {
+		field, cookie string
+	}{
+		"no cookie or token": {},
+		"no token":           {cookie: token},
+		"no cookie":          {field: token},
+		"mismatched token":   {field: strings.Repeat("cd", 32), cookie: token},
+	} {
+		form := url.Values{"only": {"a"}, "return": {"/jobs/a"}}
+		if tc.field != "" {
+			form.Set(csrfField, tc.field)
+		}
+		rec := postAction("cancel", form, tc.cookie)
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "CSRF") {
+			t.Fatalf("%s: %d %s", name, rec.Code, rec.Body)
+		}
+	}
+	if got, _ := repo.Get("a"); got.State != job.StatePending {
+		t.Fatalf("rejected action ran: job is %s", got.State)
+	}
+
+	rec := postAction("cancel", url.Values{"only": {"a"}, "return": {"/jobs/a"}, csrfField: {token}}, token)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("valid token: %d %s", rec.Code, rec.Body)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/jobs/a" {
+		t.Fatalf("Location = %q", loc)
+	}
+	var notice string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == noticeCookie {
+			notice, _ = url.QueryUnescape(c.Value)
+		}
+	}
+	if notice != "Cancelled 1 of 1 job(s)." {
+		t.Fatalf("notice = %q", notice)
+	}
+	if got, _ := repo.Get("a"); got.State != job.StateCancelled {
+		t.Fatalf("job is %s, want cancelled", got.State)
+	}
+}
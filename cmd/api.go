@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/store"
+)
+
+// maxAPIBody caps the size of API request bodies.
+const maxAPIBody = 8 << 20
+
+// apiMaxLimit is the largest page the API returns.
+const apiMaxLimit = 1000
+
+// apiError is the body of every API error response.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// jobPage is one page of a job listing; NextCursor is empty on the last page.
+type jobPage struct {
+	Jobs       []job.Job `json:"jobs"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// registerAPI adds the JSON API to the default mux. Job bodies use the same
+// JSON as "queuectl enqueue", and new jobs get the same defaults.
+func registerAPI() {
+	http.HandleFunc("GET /api/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		listJobsAPI(w, r, nil)
+	})
+	http.HandleFunc("POST /api/v1/jobs", createJobAPI)
+	http.HandleFunc("GET /api/v1/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		j, err := repo.Get(r.PathValue("id"))
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, j)
+	})
+	http.HandleFunc("DELETE /api/v1/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if err := repo.Delete(r.PathValue("id")); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	http.HandleFunc("GET /api/v1/jobs/{id}/attempts", func(w http.ResponseWriter, r *http.Request) {
+		j, err := repo.Get(r.PathValue("id"))
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		attempts, err := repo.ListAttempts(j.ID)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, attempts)
+	})
+	http.HandleFunc("POST /api/v1/jobs/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		j, err := repo.Cancel(r.PathValue("id"))
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		// A running job is only flagged; its worker records the cancellation.
+		writeJSON(w, http.StatusAccepted, j)
+	})
+	http.HandleFunc("POST /api/v1/jobs/{id}/retry", retryJobAPI)
+
+	http.HandleFunc("GET /api/v1/dlq", func(w http.ResponseWriter, r *http.Request) {
+		listJobsAPI(w, r, []job.JobState{job.StateDead})
+	})
+	http.HandleFunc("POST /api/v1/dlq/{id}/retry", retryJobAPI)
+
+	http.HandleFunc("GET /api/v1/stats", func(w http.ResponseWriter, r *http.Request) {
+		stats, err := currentStats()
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, stats)
+	})
+
+	http.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("no API endpoint %s %s", r.Method, r.URL.Path)})
+	})
+}
+
+// createJobAPI enqueues one job. A job whose idempotency key is still held
+// is not inserted; the job holding the key is returned with 200 instead of
+// 201. State may only be pending, and run results such as attempts, the
+// worker and the exit code are ignored.
+func createJobAPI(w http.ResponseWriter, r *http.Request) {
+	var j job.Job
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBody)).Decode(&j); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid job JSON: " + err.Error()})
+		return
+	}
+	if err := applyJobDefaults(&j); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+
+	err := repo.Create(&j)
+	var dup *store.DuplicateError
+	if errors.As(err, &dup) {
+		existing, err := repo.Get(dup.ExistingID)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		w.Header().Set("Location", "/api/v1/jobs/"+existing.ID)
+		writeJSON(w, http.StatusOK, existing)
+		return
+	} else if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/jobs/"+j.ID)
+	writeJSON(w, http.StatusCreated, &j)
+}
+
+// retryJobAPI moves a job from the DLQ back to pending.
+func retryJobAPI(w http.ResponseWriter, r *http.Request) {
+	j, err := repo.RetryDead(r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+// listJobsAPI serves a page of jobs. The query takes the filters of
+// "queuectl list": state (comma-separated or repeated), queue, since, until,
+// time_field, command_contains, min_attempts, priority, sort, limit and
+// cursor. states, when set, replaces the state filter.
+func listJobsAPI(w http.ResponseWriter, r *http.Request, states []job.JobState) {
+	f, err := apiJobFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	if states != nil {
+		f.States = states
+	}
+	jobs, err := repo.ListJobs(f)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	page := jobPage{Jobs: jobs}
+	if page.Jobs == nil {
+		page.Jobs = []job.Job{}
+	}
+	if len(jobs) > 0 && len(jobs) == int(f.Limit) {
+		page.NextCursor = store.JobCursor(&jobs[len(jobs)-1])
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func apiJobFilter(r *http.Request) (store.JobFilter, error) {
+	query := r.URL.Query()
+	f := store.JobFilter{
+		Queue:           query.Get("queue"),
+		TimeField:       store.TimeField(query.Get("time_field")),
+		CommandContains: query.Get("command_contains"),
+		Cursor:          query.Get("cursor"),
+		Limit:           100,
+	}
+	for _, value := range query["state"] {
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				f.States = append(f.States, job.JobState(s))
+			}
+		}
+	}
+
+	var err error
+	if f.Since, err = parseTimeFlag(query.Get("since")); err != nil {
+		return f, fmt.Errorf("invalid since: %v", err)
+	}
+	if f.Until, err = parseTimeFlag(query.Get("until")); err != nil {
+		return f, fmt.Errorf("invalid until: %v", err)
+	}
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > apiMaxLimit {
+			return f, fmt.Errorf("invalid limit %q (want 1 to %d)", s, apiMaxLimit)
+		}
+		f.Limit = int32(n)
+	}
+	if s := query.Get("min_attempts"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return f, fmt.Errorf("invalid min_attempts %q", s)
+		}
+		f.MinAttempts = int32(n)
+	}
+	if s := query.Get("priority"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return f, fmt.Errorf("invalid priority %q", s)
+		}
+		f.Priority = &n
+	}
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = "priority"
+	}
+	if err := applySort(&f, sort); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+// writeAPIError answers with the status matching a store error.
+func writeAPIError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, store.ErrConflict), errors.Is(err, store.ErrJobExists):
+		status = http.StatusConflict
+	case errors.Is(err, store.ErrInvalidJob), errors.Is(err, store.ErrInvalidFilter):
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
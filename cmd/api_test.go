@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/store"
+)
+
+var registerAPIOnce sync.Once
+
+// serveAPI sends one request to the API routes on the default mux.
+func serveAPI(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	registerAPIOnce.Do(registerAPI)
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(rec, req)
+	return rec
+}
+
+// useMemoryStore points the package's repo at a fresh in-memory store for
+// the rest of the test.
+func useMemoryStore(t *testing.T) {
+	t.Helper()
+	jobs, err := store.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := repo
+	repo = jobs
+	t.Cleanup(func() {
+		repo = old
+		jobs.Close()
+	})
+}
+
+func decodeBody[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(rec.Body).Decode(&v); err != nil {
+		t.Fatalf("decode %d response: %v", rec.Code, err)
+	}
+	return v
+}
+
+func TestCreateJobAPI(t *testing.T) {
+	useMemoryStore(t)
+
+	rec := serveAPI(t, "POST", "/api/v1/jobs", `{"id":"a","command":"echo hi","state":"pending",
+		"attempts":7,"worker_id":"w1","lease_expires_at":"2030-01-01T00:00:00Z","exit_code":3,
+		"cancel_requested":true,"last_error":"boom","output":"x","duration":1.5}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/api/v1/jobs/a" {
+		t.Fatalf("Location = %q", loc)
+	}
+	stored, err := repo.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.State != job.StatePending || stored.Attempts != 0 || stored.WorkerID != nil ||
+		stored.LeaseExpiresAt != nil || stored.ExitCode != nil || stored.CancelRequested ||
+		stored.LastError != nil || stored.Output != "" || stored.Duration != 0 {
+		t.Fatalf("server-owned fields kept: %+v", stored)
+	}
+
+	rec = serveAPI(t, "POST", "/api/v1/jobs", `{"id":"a","command":"echo again"}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("taken ID: %d %s", rec.Code, rec.Body)
+	}
+	if body := decodeBody[apiError](t, rec); !strings.Contains(body.Error, "already exists") {
+		t.Fatalf("taken ID error = %q", body.Error)
+	}
+
+	for _, body := range []string{`{"command":`, `{"command":"true","state":"completed"}`, `{"command":"true","state":"processing"}`} {
+		rec = serveAPI(t, "POST", "/api/v1/jobs", body)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: %d %s", body, rec.Code, rec.Body)
+		}
+		if decodeBody[apiError](t, rec).Error == "" {
+			t.Fatalf("%s: empty error", body)
+		}
+	}
+
+	rec = serveAPI(t, "GET", "/api/v1/jobs/nope", "")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown job: %d %s", rec.Code, rec.Body)
+	}
+	if body := decodeBody[apiError](t, rec); body.Error != "job not found" {
+		t.Fatalf("unknown job error = %q", body.Error)
+	}
+}
+
+func TestCreateJobAPIReturnsKeyHolder(t *testing.T) {
+	useMemoryStore(t)
+
+	rec := serveAPI(t, "POST", "/api/v1/jobs", `{"command":"true","idempotency_key":"nightly"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("first: %d %s", rec.Code, rec.Body)
+	}
+	first := decodeBody[job.Job](t, rec)
+
+	rec = serveAPI(t, "POST", "/api/v1/jobs", `{"command":"true","idempotency_key":"nightly"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("duplicate: %d %s", rec.Code, rec.Body)
+	}
+	if got := decodeBody[job.Job](t, rec); got.ID != first.ID {
+		t.Fatalf("duplicate returned %s, want %s", got.ID, first.ID)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/api/v1/jobs/"+first.ID {
+		t.Fatalf("Location = %q", loc)
+	}
+}
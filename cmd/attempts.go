@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/store"
+)
+
+var attemptsShowOutput bool
+
+var attemptsCmd = &cobra.Command{
+	Use:     "attempts [job-id]",
+	Aliases: []string{"history"},
+	Short:   "Show every execution attempt of a job",
+	Long: `Show the attempt history of a job: who ran it, when, how long, the exit
+code, the resulting state and the error of every execution.
+
+Examples:
+  queuectl attempts job-123
+  queuectl attempts job-123 --show-output`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		CommonInit()
+
+		j, err := repo.Get(args[0])
+		if errors.Is(err, store.ErrNotFound) {
+			log.Fatalf("Job %s not found", args[0])
+		} else if err != nil {
+			log.Fatalf("Failed to fetch job: %v", err)
+		}
+		attempts, err := repo.ListAttempts(j.ID)
+		if err != nil {
+			log.Fatalf("Failed to fetch attempts: %v", err)
+		}
+		if printStructured(attempts) {
+			return
+		}
+
+		fmt.Printf("Job %s (%s) is %s after %d attempt(s)\n", j.ID, j.CommandLine(), j.State, len(attempts))
+		for _, a := range attempts {
+			state := string(a.State)
+			if a.FinishedAt == nil {
+				state = "running"
+			} else if state == "" {
+				state = "discarded"
+			}
+			exit := "-"
+			if a.ExitCode != nil {
+				exit = fmt.Sprint(*a.ExitCode)
+			}
+			fmt.Printf("#%d | %s | %s | %.2fs | exit %s | %s",
+				a.Number, a.StartedAt.Local().Format(time.DateTime), a.WorkerID, a.Duration().Seconds(), exit, state)
+			if a.Error != nil {
+				fmt.Printf(" | error: %s", *a.Error)
+			}
+			fmt.Println()
+			if attemptsShowOutput {
+				printAttemptOutput(j.ID, a.Number, job.StreamStdout, a.Stdout)
+				printAttemptOutput(j.ID, a.Number, job.StreamStderr, a.Stderr)
+			}
+		}
+	},
+}
+
+// printAttemptOutput prints one stream of an attempt from job_logs, marking
+// where the size cap dropped output. Attempts recorded before output went to
+// job_logs only have their preview.
+func printAttemptOutput(jobID string, attempt int, stream, preview string) {
+	text, err := attemptOutput(jobID, attempt, stream, preview)
+	if err != nil {
+		log.Fatalf("Failed to fetch output: %v", err)
+	}
+	if text = strings.TrimSpace(text); text != "" {
+		fmt.Printf("  %s:\n%s\n", stream, text)
+	}
+}
+
+// attemptOutput returns the full output of one attempt's stream, with a
+// marker where chunks were dropped, or preview if no chunks were kept.
+func attemptOutput(jobID string, attempt int, stream, preview string) (string, error) {
+	chunks, err := repo.ReadLogs(store.LogQuery{JobID: jobID, Attempt: attempt, Stream: stream})
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if len(chunks) == 0 {
+		out.WriteString(preview)
+	}
+	gaps := job.LogGaps{}
+	for _, c := range chunks {
+		if n := gaps.Skipped(&c); n > 0 {
+			out.WriteString(job.TruncationMarker(n))
+		}
+		out.WriteString(c.Data)
+	}
+	return out.String(), nil
+}
+
+func init() {
+	attemptsCmd.Flags().BoolVarP(&attemptsShowOutput, "show-output", "o", false, "also print each attempt's stdout and stderr")
+	rootCmd.AddCommand(attemptsCmd)
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"queuectl.backend/internal/config"
+)
+
+var authRole string
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage users and API tokens of the web dashboard and API",
+	Long: `Manage who may use "queuectl web" and worker metrics endpoints. The web-auth
+config key picks the credentials the server accepts: none (the default),
+basic (users with passwords), token (API tokens) or basic,token. Only hashes
+of passwords and tokens are stored. Changes apply within a few seconds.
+
+Roles: viewer reads jobs, logs, events and metrics; operator also enqueues,
+cancels, retries and reprioritizes jobs; admin also deletes them.
+
+Examples:
+  echo "$PASSWORD" | queuectl auth add-user alice --role operator
+  queuectl auth add-token prometheus --role viewer
+  queuectl config set --key web-auth --value basic,token
+  curl -H "Authorization: Bearer $TOKEN" localhost:8080/api/v1/stats`,
+}
+
+var authListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List users and API tokens",
+	Run: func(cmd *cobra.Command, args []string) {
+		CommonInit()
+		settings, err := config.NewRepository(repo.DB()).WebAuth()
+		if err != nil {
+			log.Fatalf("Failed to read credentials: %v", err)
+		}
+		if printStructured(settings) {
+			return
+		}
+		fmt.Printf("Auth mode: %s\n", settings.Modes)
+		if len(settings.Users)+len(settings.Tokens) == 0 {
+			fmt.Println("No users or tokens defined.")
+			return
+		}
+		for _, u := range settings.Users {
+			fmt.Printf("- user %s | %s\n", u.Name, u.Role)
+		}
+		for _, t := range settings.Tokens {
+			fmt.Printf("- token %s | %s\n", t.Name, t.Role)
+		}
+	},
+}
+
+var authAddUserCmd = &cobra.Command{
+	Use:   "add-user <name>",
+	Short: "Add a user, or change a user's password and role (password read from stdin)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		CommonInit()
+		role := parseAuthRole()
+		if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			fmt.Fprint(os.Stderr, "Password: ")
+		}
+		password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && password == "" {
+			log.Fatalf("Failed to read password: %v", err)
+		}
+		password = strings.TrimRight(password, "\r\n")
+		if err := config.NewRepository(repo.DB()).SetUser(args[0], password, role); err != nil {
+			log.Fatalf("Failed to set user: %v", err)
+		}
+		fmt.Printf("User %s saved with role %s\n", args[0], role)
+	},
+}
+
+var authAddTokenCmd = &cobra.Command{
+	Use:   "add-token <name>",
+	Short: "Create an API token, replacing any token of the same name",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		CommonInit()
+		role := parseAuthRole()
+		token, err := config.NewRepository(repo.DB()).CreateToken(args[0], role)
+		if err != nil {
+			log.Fatalf("Failed to create token: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Token %s created with role %s. It is shown only once:\n", args[0], role)
+		fmt.Println(token)
+	},
+}
+
+// authRemoveCmd builds the remove-user and remove-token subcommands.
+func authRemoveCmd(kind string, remove func(r *config.Repository, name string) error) *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove-" + kind + " <name>",
+		Short: "Remove a " + kind,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			CommonInit()
+			err := remove(config.NewRepository(repo.DB()), args[0])
+			if errors.Is(err, config.ErrCredentialNotFound) {
+				log.Fatalf("No %s named %s", kind, args[0])
+			} else if err != nil {
+				log.Fatalf("Failed to remove %s: %v", kind, err)
+			}
+			fmt.Printf("Removed %s %s\n", kind, args[0])
+		},
+	}
+}
+
+func parseAuthRole() config.Role {
+	role, err := config.ParseRole(authRole)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return role
+}
+
+func init() {
+	for _, c := range []*cobra.Command{authAddUserCmd, authAddTokenCmd} {
+		c.Flags().StringVar(&authRole, "role", config.RoleViewer.String(), "viewer, operator or admin")
+	}
+	authCmd.AddCommand(authListCmd)
+	authCmd.AddCommand(authAddUserCmd)
+	authCmd.AddCommand(authAddTokenCmd)
+	authCmd.AddCommand(authRemoveCmd("user", (*config.Repository).RemoveUser))
+	authCmd.AddCommand(authRemoveCmd("token", (*config.Repository).RemoveToken))
+	rootCmd.AddCommand(authCmd)
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/store"
+)
+
+var (
+	cancelStates []string
+	cancelQueue  string
+)
+
+var cancelCmd = &cobra.Command{
+	Use:   "cancel [job-id...]",
+	Short: "Cancel pending or running jobs",
+	Long: `Cancel jobs by ID, or every unfinished job matching --state/--queue.
+
+Jobs that have not started (pending, failed, blocked) are cancelled at once.
+For a processing job the owning worker is told to kill its process group on
+its next heartbeat, after which the job is marked cancelled.
+
+Examples:
+  queuectl cancel job-123
+  queuectl cancel --queue emails
+  queuectl cancel --state pending,failed`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 && len(cancelStates) == 0 && cancelQueue == "" {
+			log.Fatal("Give job IDs or a --state/--queue filter to cancel")
+		}
+		CommonInit()
+
+		var jobs []job.Job
+		failed := false
+		for _, id := range args {
+			j, err := repo.Cancel(id)
+			if err != nil {
+				fmt.Printf("Job %s not cancelled: %v\n", id, err)
+				failed = true
+				continue
+			}
+			jobs = append(jobs, *j)
+		}
+
+		if len(cancelStates) > 0 || cancelQueue != "" {
+			var states []job.JobState
+			for _, s := range cancelStates {
+				states = append(states, job.JobState(s))
+			}
+			matched, err := repo.CancelAll(store.JobFilter{States: states, Queue: cancelQueue})
+			jobs = append(jobs, matched...)
+			if err != nil {
+				fmt.Printf("Bulk cancel stopped early: %v\n", err)
+				failed = true
+			}
+		}
+
+		for _, j := range jobs {
+			if j.State == job.StateProcessing {
+				fmt.Printf("Job %s is running; cancellation requested from its worker\n", j.ID)
+			} else {
+				fmt.Printf("Job %s cancelled\n", j.ID)
+			}
+		}
+		if len(jobs) == 0 && !failed {
+			fmt.Println("No matching jobs to cancel.")
+		}
+		if failed {
+			log.Fatal("Some jobs could not be cancelled")
+		}
+	},
+}
+
+func init() {
+	cancelCmd.Flags().StringSliceVarP(&cancelStates, "state", "s", nil, "cancel every unfinished job in these states")
+	cancelCmd.Flags().StringVar(&cancelQueue, "queue", "", "cancel every unfinished job in this queue")
+	rootCmd.AddCommand(cancelCmd)
+}
@@ -6,7 +6,7 @@ import (
 	"queuectl.backend/internal/store"
 )
 
-var repo *store.JobRepo
+var repo store.JobStore
 
 //initializing the db once
 
@@ -14,10 +14,10 @@ func CommonInit() {
 	if repo != nil {
 		return
 	}
-	db, err := store.InitDB()
+	var err error
+	repo, err = store.Open(databaseDSN())
 	if err != nil {
-		log.Fatalf("Initialization of the database failed")
+		log.Fatalf("Initialization of the database failed: %v", err)
 	}
-	repo = store.NewJobRepo(db)
 	log.Println("Database initialized and repository ready")
 }
@@ -3,8 +3,10 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	"queuectl.backend/internal/config"
+	"queuectl.backend/internal/job"
 	"github.com/spf13/cobra"
 )
 
@@ -20,6 +22,25 @@ var setCmd = &cobra.Command{
 	Short: "Set a configuration value",
 	Run: func(cmd *cobra.Command, args []string) {
 		CommonInit()
+		switch cfgKey {
+		case config.KeyRetryOnExitCodes, config.KeyFailFastExitCodes:
+			if _, err := job.ParseExitCodes(cfgValue); err != nil {
+				log.Fatalf("Invalid %s: %v", cfgKey, err)
+			}
+		case config.KeyMaxLogSize:
+			if size, err := config.ParseSize(cfgValue); err != nil {
+				log.Fatalf("Invalid %s: %v", cfgKey, err)
+			} else if size < config.MinLogSize {
+				log.Fatalf("Invalid %s: must be at least %d bytes", cfgKey, config.MinLogSize)
+			}
+		case config.KeyWebAuth:
+			if _, err := config.ParseAuthModes(cfgValue); err != nil {
+				log.Fatalf("Invalid %s: %v", cfgKey, err)
+			}
+		}
+		if strings.HasPrefix(cfgKey, config.UserKeyPrefix) || strings.HasPrefix(cfgKey, config.TokenKeyPrefix) {
+			log.Fatalf("Use \"queuectl auth\" to manage users and tokens")
+		}
 		repoCfg := config.NewRepository(repo.DB())
 		if err := repoCfg.Set(cfgKey, cfgValue); err != nil {
 			log.Fatalf("Failed to set config: %v", err)
@@ -34,10 +55,20 @@ var viewCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		CommonInit()
 		repoCfg := config.NewRepository(repo.DB())
-		items, err := repoCfg.All()
+		all, err := repoCfg.All()
 		if err != nil {
 			log.Fatalf("Failed to fetch config: %v", err)
 		}
+		// Users and tokens are listed by "queuectl auth list".
+		items := []config.Config{}
+		for _, i := range all {
+			if !strings.HasPrefix(i.Key, config.UserKeyPrefix) && !strings.HasPrefix(i.Key, config.TokenKeyPrefix) {
+				items = append(items, i)
+			}
+		}
+		if printStructured(items) {
+			return
+		}
 		if len(items) == 0 {
 			fmt.Println("No configuration values set.")
 			return
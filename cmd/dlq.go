@@ -4,52 +4,63 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/spf13/cobra"
 	"queuectl.backend/internal/job"
 	"queuectl.backend/internal/store"
 )
 
-var retryID string
+var (
+	retryID   string
+	dlqLimit  int32
+	dlqCursor string
+)
 
 var dlqCmd = &cobra.Command{
 	Use:   "dlq",
 	Short: "View or retry jobs in the Dead Letter Queue",
-	Long: `Examples:
+	Long: `Dead jobs are listed newest first, one page at a time (--limit, default
+200). When more remain, the next page's --cursor is printed after the list
+(on stderr with --output).
+
+Examples:
   queuectl dlq
+  queuectl dlq --limit 50 --cursor <cursor from the previous page>
   queuectl dlq --retry <job-id>`,
 	Run: func(cmd *cobra.Command, args []string) {
-		CommonInit()
-		db, err := store.InitDB()
-		if err != nil {
-			log.Fatal("DB init failed:", err)
+		if dlqLimit <= 0 {
+			log.Fatal("--limit must be positive")
 		}
-		repo := store.NewJobRepo(db)
+		CommonInit()
 
 		if retryID != "" {
-			// fetch dead job by id
-			var j job.Job
-			if err := repo.DB().Where("id = ? AND state = ?", retryID, job.StateDead).First(&j).Error; err != nil {
+			// reset the dead job and move it back to pending
+			j, err := repo.RetryDead(retryID)
+			if err != nil {
 				log.Fatalf("DLQ retry failed: %v", err)
 			}
-			// reset and move back to pending
-			j.State = job.StatePending
-			j.Attempts = 0
-			j.RunAt = nil
-			j.LastError = nil
-			if err := repo.Update(&j); err != nil {
-				log.Fatalf("DLQ retry update failed: %v", err)
-			}
 			fmt.Printf("DLQ: job %s moved back to pending\n", j.ID)
 			return
 		}
 
 		// list DLQ
-		dead := job.StateDead
-		jobs, err := repo.ListJobs([]job.JobState{dead}, 200, 0, true)
+		jobs, err := repo.ListJobs(store.JobFilter{
+			States:      []job.JobState{job.StateDead},
+			Limit:       dlqLimit,
+			NewestFirst: true,
+			Cursor:      dlqCursor,
+		})
 		if err != nil {
 			log.Fatal("Failed to list DLQ:", err)
 		}
+		next := nextCursor(jobs, dlqLimit)
+		if printStructured(jobs) {
+			if next != "" {
+				fmt.Fprintf(os.Stderr, "next cursor: %s\n", next)
+			}
+			return
+		}
 		if len(jobs) == 0 {
 			fmt.Println("DLQ is empty")
 			return
@@ -60,13 +71,22 @@ var dlqCmd = &cobra.Command{
 			if j.LastError != nil {
 				msg = *j.LastError
 			}
-			fmt.Printf("- %s | %s | attempts %d/%d | last_error: %s\n",
-				j.ID, j.Command, j.Attempts, j.MaxRetries, msg)
+			exit := "-"
+			if j.ExitCode != nil {
+				exit = fmt.Sprint(*j.ExitCode)
+			}
+			fmt.Printf("- %s | %s | queue %s | attempts %d/%d | exit %s | last_error: %s\n",
+				j.ID, j.CommandLine(), j.Queue, j.Attempts, j.MaxRetries, exit, msg)
+		}
+		if next != "" {
+			fmt.Printf("More dead jobs; next page: --cursor %s\n", next)
 		}
 	},
 }
 
 func init() {
 	dlqCmd.Flags().StringVar(&retryID, "retry", "", "retry a specific DLQ job id (moves it back to pending)")
+	dlqCmd.Flags().Int32Var(&dlqLimit, "limit", 200, "maximum number of dead jobs to show")
+	dlqCmd.Flags().StringVar(&dlqCursor, "cursor", "", "continue after the last job of a previous page")
 	rootCmd.AddCommand(dlqCmd)
 }
@@ -1,72 +1,92 @@
 package cmd
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/spf13/cobra"
 	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/store"
+)
+
+var (
+	enqueueFile       string
+	enqueueBestEffort bool
 )
 
 // enqueueCmd represents the "enqueue" command
 var enqueueCmd = &cobra.Command{
-	Use:   "enqueue [job-json]",
+	Use:   "enqueue [job-json | -]",
 	Short: "Add a new job to the queue (supports scheduling and priority)",
 	Long: `Add a new job to the background queue.
 
+With --file (or "-" for stdin) every line of a JSONL file is one job, and all
+of them are inserted in a single transaction. By default one bad line rejects
+the whole file; --best-effort inserts the valid lines and reports the rest.
+Blank lines and lines starting with # are ignored. --priority, --delay,
+--run-at and --queue apply to every job in the file.
+
+A job with an "idempotency_key" is not enqueued while an earlier job still
+holds the same key; the existing job's ID is reported instead. How long a key
+is held depends on "unique_scope": forever (the default), pending (until the
+job starts), unfinished (until it completes, dies or is cancelled) or window
+(for "unique_for" after it was enqueued, e.g. "10m").
+
 Examples:
   queuectl enqueue '{"command":"echo Hello World"}'
   queuectl enqueue '{"command":"echo High Priority"}' --priority 10
   queuectl enqueue '{"command":"echo Run Later"}' --delay 30s
-  queuectl enqueue '{"command":"echo Scheduled"}' --run-at "2025-11-09T01:00:00Z"`,
-	Args: cobra.ExactArgs(1),
+  queuectl enqueue '{"command":"echo Scheduled"}' --run-at "2025-11-09T01:00:00Z"
+  queuectl enqueue '{"command":"./send-digest.sh"}' --queue emails
+  queuectl enqueue '{"command":"./bill.sh 42","idempotency_key":"invoice-42"}'
+  queuectl enqueue '{"command":"./sync.sh","idempotency_key":"sync","unique_scope":"pending"}'
+  queuectl enqueue --file jobs.jsonl
+  generate-jobs | queuectl enqueue - --best-effort`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		switch {
+		case len(args) == 1 && args[0] == "-":
+			enqueueFile = "-"
+		case len(args) == 1 && enqueueFile != "":
+			log.Fatal("Give either a job JSON argument or --file, not both")
+		case len(args) == 0 && enqueueFile == "":
+			log.Fatal("Give a job JSON argument, --file <path> or - for stdin")
+		}
 		CommonInit()
 
+		if enqueueFile != "" {
+			enqueueBatch(cmd, enqueueFile)
+			return
+		}
+
 		var j job.Job
 		if err := json.Unmarshal([]byte(args[0]), &j); err != nil {
 			log.Fatalf("Invalid job JSON: %v", err)
 		}
-
-		if j.ID == "" {
-			j.ID = fmt.Sprintf("job-%d", time.Now().UnixNano())
-		}
-		if j.State == "" {
-			j.State = job.StatePending
-		}
-		if j.MaxRetries == 0 {
-			j.MaxRetries = 3
-		}
-		j.CreatedAt = time.Now().UTC()
-		j.UpdatedAt = j.CreatedAt
-
-		priority, _ := cmd.Flags().GetInt("priority")
-		j.Priority = priority
-
-		delay, _ := cmd.Flags().GetDuration("delay")
-		if delay > 0 {
-			runAt := time.Now().Add(delay).UTC()
-			j.RunAt = &runAt
-		}
-
-		runAtStr, _ := cmd.Flags().GetString("run-at")
-		if runAtStr != "" {
-			parsedTime, err := time.Parse(time.RFC3339, runAtStr)
-			if err != nil {
-				log.Fatalf("Invalid --run-at value, must use RFC3339 format (e.g., 2025-11-09T01:00:00Z): %v", err)
-			}
-			j.RunAt = &parsedTime
+		if err := prepareJob(cmd, &j); err != nil {
+			log.Fatal(err)
 		}
 
 		// Save to DB
 		if err := repo.Create(&j); err != nil {
+			var dup *store.DuplicateError
+			if errors.As(err, &dup) {
+				fmt.Printf("Job %s already exists for idempotency key %q; nothing enqueued\n", dup.ExistingID, dup.Key)
+				return
+			}
 			log.Fatalf("Failed to enqueue job: %v", err)
 		}
 
 		// Friendly output
-		fmt.Printf("Job %s enqueued successfully", j.ID)
+		fmt.Printf("Job %s enqueued successfully on queue %s", j.ID, j.Queue)
 		if j.Priority > 0 {
 			fmt.Printf(" (priority=%d)", j.Priority)
 		}
@@ -79,9 +99,152 @@ Examples:
 	},
 }
 
+// prepareJob fills in the defaults of a job parsed from enqueue JSON and
+// applies the scheduling flags of cmd.
+func prepareJob(cmd *cobra.Command, j *job.Job) error {
+	if queueName, _ := cmd.Flags().GetString("queue"); queueName != "" {
+		j.Queue = queueName
+	}
+	if err := applyJobDefaults(j); err != nil {
+		return err
+	}
+
+	if cmd.Flags().Changed("priority") {
+		j.Priority, _ = cmd.Flags().GetInt("priority")
+	}
+
+	delay, _ := cmd.Flags().GetDuration("delay")
+	if delay > 0 {
+		runAt := time.Now().Add(delay).UTC()
+		j.RunAt = &runAt
+	}
+
+	runAtStr, _ := cmd.Flags().GetString("run-at")
+	if runAtStr != "" {
+		parsedTime, err := time.Parse(time.RFC3339, runAtStr)
+		if err != nil {
+			return fmt.Errorf("invalid --run-at value, must use RFC3339 format (e.g., 2025-11-09T01:00:00Z): %v", err)
+		}
+		j.RunAt = &parsedTime
+	}
+	return nil
+}
+
+// applyJobDefaults validates a job parsed from enqueue JSON, fills in the
+// defaults (see job.Normalize) and generates its ID unless it has one; it is
+// shared by "queuectl enqueue" and the web API.
+func applyJobDefaults(j *job.Job) error {
+	if err := j.Normalize(); err != nil {
+		return err
+	}
+	if j.ID == "" {
+		j.ID = newJobID()
+	}
+	return nil
+}
+
+// lastJobID is the numeric part of the last ID handed out by newJobID.
+var lastJobID atomic.Int64
+
+// newJobID returns "job-<UnixNano>", bumped when the clock has not moved so
+// jobs created in one batch, or by concurrent API requests, never collide.
+func newJobID() string {
+	for {
+		last := lastJobID.Load()
+		n := max(time.Now().UnixNano(), last+1)
+		if lastJobID.CompareAndSwap(last, n) {
+			return fmt.Sprintf("job-%d", n)
+		}
+	}
+}
+
+// enqueueBatch inserts every job of a JSONL file (or stdin for "-") and
+// reports rejected lines by line number.
+func enqueueBatch(cmd *cobra.Command, path string) {
+	var in io.Reader = os.Stdin
+	source := "stdin"
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Failed to open job file: %v", err)
+		}
+		defer f.Close()
+		in, source = f, path
+	}
+
+	var jobs []*job.Job
+	var lines []int // line number of each job in jobs
+	var problems []string
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var j job.Job
+		err := json.Unmarshal([]byte(line), &j)
+		if err == nil {
+			err = prepareJob(cmd, &j)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: %v", n, err))
+			continue
+		}
+		jobs = append(jobs, &j)
+		lines = append(lines, n)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Failed to read %s: %v", source, err)
+	}
+
+	if len(problems) > 0 && !enqueueBestEffort {
+		reportBatchProblems(problems)
+		log.Fatalf("No jobs enqueued: %d line(s) of %s rejected (use --best-effort to skip them)", len(problems), source)
+	}
+
+	rejected, err := repo.CreateBatch(jobs, !enqueueBestEffort)
+	if err != nil {
+		log.Fatalf("Failed to enqueue jobs: %v", err)
+	}
+	failed, duplicates := 0, 0
+	for _, r := range rejected {
+		var dup *store.DuplicateError
+		if errors.As(r.Err, &dup) {
+			fmt.Fprintf(os.Stderr, "line %d: duplicate of job %s (idempotency key %q), skipped\n", lines[r.Index], dup.ExistingID, dup.Key)
+			duplicates++
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("line %d: %v", lines[r.Index], r.Err))
+		failed++
+	}
+	reportBatchProblems(problems)
+
+	if !enqueueBestEffort && failed > 0 {
+		log.Fatalf("No jobs enqueued: %d line(s) of %s rejected (use --best-effort to skip them)", failed, source)
+	}
+	fmt.Printf("Enqueued %d job(s) from %s", len(jobs)-len(rejected), source)
+	if duplicates > 0 {
+		fmt.Printf(" (%d duplicate(s) skipped)", duplicates)
+	}
+	fmt.Println()
+	if len(problems) > 0 {
+		log.Fatalf("%d line(s) of %s rejected", len(problems), source)
+	}
+}
+
+func reportBatchProblems(problems []string) {
+	for _, p := range problems {
+		fmt.Fprintln(os.Stderr, p)
+	}
+}
+
 func init() {
 	enqueueCmd.Flags().IntP("priority", "p", 0, "set job priority (higher = more important)")
 	enqueueCmd.Flags().Duration("delay", 0, "schedule job to run after a delay (e.g., 10s, 1m, 2h)")
 	enqueueCmd.Flags().String("run-at", "", "specific time to run the job (RFC3339 format, e.g., 2025-11-09T01:00:00Z)")
+	enqueueCmd.Flags().String("queue", "", "queue to place the job on (default \"default\", or the job JSON's \"queue\")")
+	enqueueCmd.Flags().StringVarP(&enqueueFile, "file", "f", "", "enqueue every line of a JSONL file (- for stdin)")
+	enqueueCmd.Flags().BoolVar(&enqueueBestEffort, "best-effort", false, "with --file, insert the valid lines even if others are rejected")
 	rootCmd.AddCommand(enqueueCmd)
 }
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"queuectl.backend/internal/job"
+)
+
+func TestNewJobIDIsUniqueAcrossGoroutines(t *testing.T) {
+	const goroutines, each = 8, 500
+	ids := make(chan string, goroutines*each)
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range each {
+				ids <- newJobID()
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := map[string]bool{}
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("duplicate job ID %s", id)
+		}
+		seen[id] = true
+	}
+}
+
+// serverOwned is enqueue JSON that tries to set what only workers and the
+// store may.
+const serverOwned = `"attempts":5,"worker_id":"w1","lease_expires_at":"2030-01-01T00:00:00Z",` +
+	`"exit_code":2,"cancel_requested":true,"last_error":"boom","output":"x","duration":3`
+
+func TestEnqueueRefusesStatesOtherThanPending(t *testing.T) {
+	for _, state := range []string{"dead", "completed", "processing", "blocked"} {
+		var j job.Job
+		if err := json.Unmarshal([]byte(`{"command":"x","state":"`+state+`"}`), &j); err != nil {
+			t.Fatal(err)
+		}
+		if err := prepareJob(enqueueCmd, &j); err == nil || !strings.Contains(err.Error(), "new jobs start pending") {
+			t.Fatalf("state %s: prepareJob = %v", state, err)
+		}
+	}
+}
+
+func TestEnqueueClearsServerOwnedFields(t *testing.T) {
+	useMemoryStore(t)
+	path := filepath.Join(t.TempDir(), "jobs.jsonl")
+	lines := `{"id":"b1","command":"true","state":"pending",` + serverOwned + "}\n" +
+		`{"id":"b2","command":"true",` + serverOwned + "}\n"
+	if err := os.WriteFile(path, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rootCmd.SetArgs([]string{"enqueue", `{"id":"one","command":"true",` + serverOwned + "}"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	rootCmd.SetArgs([]string{"enqueue", "--file", path})
+	t.Cleanup(func() { enqueueFile = "" })
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"one", "b1", "b2"} {
+		j, err := repo.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j.State != job.StatePending || j.Attempts != 0 || j.WorkerID != nil || j.LeaseExpiresAt != nil ||
+			j.ExitCode != nil || j.CancelRequested || j.LastError != nil || j.Output != "" || j.Duration != 0 {
+			t.Fatalf("%s kept server-owned fields: %+v", id, j)
+		}
+	}
+}
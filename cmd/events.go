@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/store"
+)
+
+// eventsPollInterval is how often the event stream checks for changed jobs.
+const eventsPollInterval = time.Second
+
+// eventsReplay is how far before the requested time a resumed stream
+// starts.
+const eventsReplay = 5 * time.Second
+
+// eventsKeepAlive is how long an event stream may stay silent before it
+// sends a comment, so proxies do not drop the connection.
+const eventsKeepAlive = 15 * time.Second
+
+// jobRow is the dashboard's view of a job, sent in "job" events.
+type jobRow struct {
+	ID         string       `json:"id"`
+	Command    string       `json:"command"`
+	Queue      string       `json:"queue"`
+	State      job.JobState `json:"state"`
+	Priority   int          `json:"priority"`
+	Attempts   int32        `json:"attempts"`
+	MaxRetries int32        `json:"max_retries"`
+	ExitCode   *int         `json:"exit_code,omitempty"`
+	RunAt      *time.Time   `json:"run_at,omitempty"`
+	Duration   float64      `json:"duration"`
+	CreatedAt  time.Time    `json:"created_at"`
+	Deleted    bool         `json:"deleted,omitempty"`
+	// Actions names the dashboard actions the job's row offers.
+	Actions []string `json:"actions"`
+}
+
+func newJobRow(j *job.Job) jobRow {
+	return jobRow{
+		ID:         j.ID,
+		Command:    j.CommandLine(),
+		Queue:      j.Queue,
+		State:      j.State,
+		Priority:   j.Priority,
+		Attempts:   j.Attempts,
+		MaxRetries: j.MaxRetries,
+		ExitCode:   j.ExitCode,
+		RunAt:      j.RunAt,
+		Duration:   j.Duration,
+		CreatedAt:  j.CreatedAt,
+		Deleted:    j.DeletedAt.Valid,
+		Actions:    rowActionNames(j),
+	}
+}
+
+// queueStats is the dashboard counters, sent in "stats" events and served
+// by the API.
+type queueStats struct {
+	store.MetricsSummary
+	Queues []store.QueueSummary `json:"queues"`
+}
+
+func currentStats() (queueStats, error) {
+	metrics, err := repo.JobMetrics()
+	if err != nil {
+		return queueStats{}, err
+	}
+	queues, err := repo.QueueMetrics()
+	return queueStats{metrics, queues}, err
+}
+
+// serveEvents streams dashboard updates as Server-Sent Events: a "job" event
+// for every job that changes (deleted jobs have "deleted": true) and a
+// "stats" event whenever the counters change. The query takes queue=, to
+// send only that queue's jobs, and since=, an RFC 3339 time to replay
+// changes from; job event IDs are such times, so a reconnecting browser
+// resumes where it left off.
+func serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	since := time.Now().UTC()
+	if s := cmp.Or(r.Header.Get("Last-Event-ID"), r.URL.Query().Get("since")); s != "" {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid since %q", s), http.StatusBadRequest)
+			return
+		}
+		// Replay a little before it: a change committed late may carry an
+		// earlier time than the last one sent. Repeated events are harmless.
+		since = t.Add(-eventsReplay)
+	}
+	queueName := r.URL.Query().Get("queue")
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	var lastStats []byte
+	lastWrite := time.Now()
+	err := store.WatchJobs(r.Context(), repo, since, eventsPollInterval, func(jobs []job.Job) error {
+		var buf bytes.Buffer
+		for _, j := range jobs {
+			if queueName != "" && j.Queue != queueName {
+				continue
+			}
+			writeEvent(&buf, "job", j.UpdatedAt.Format(time.RFC3339Nano), newJobRow(&j))
+		}
+		// Counters only move when jobs change.
+		if len(jobs) > 0 || lastStats == nil {
+			stats, err := currentStats()
+			if err != nil {
+				return err
+			}
+			data, _ := json.Marshal(stats)
+			if !bytes.Equal(data, lastStats) {
+				writeEvent(&buf, "stats", "", stats)
+				lastStats = data
+			}
+		}
+		if buf.Len() == 0 {
+			if time.Since(lastWrite) < eventsKeepAlive {
+				return nil
+			}
+			buf.WriteString(": keep-alive\n\n")
+		}
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		flusher.Flush()
+		lastWrite = time.Now()
+		return nil
+	})
+	if err != nil && r.Context().Err() == nil {
+		log.Printf("web: event stream: %v", err)
+	}
+}
+
+// writeEvent appends one Server-Sent Event with a JSON payload to buf.
+func writeEvent(buf *bytes.Buffer, name, id string, v any) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(buf, "event: %s\n", name)
+	if id != "" {
+		fmt.Fprintf(buf, "id: %s\n", id)
+	}
+	fmt.Fprintf(buf, "data: %s\n\n", data)
+}
@@ -3,6 +3,9 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"queuectl.backend/internal/job"
@@ -10,45 +13,147 @@ import (
 )
 
 var (
-	stateFilter string
-	showOutput  bool
+	stateFilter     []string
+	queueFilter     string
+	showOutput      bool
+	listLimit       int32
+	listCursor      string
+	listSince       string
+	listUntil       string
+	listTimeField   string
+	commandContains string
+	minAttempts     int32
+	priorityFilter  int
+	listSort        string
 )
 
 var listCmd = &cobra.Command{
 	Use:   "list",
-	Short: "List jobs by state (pending, processing, completed, failed, dead)",
-	Long:  "Display jobs in the queue with optional output display.",
+	Short: "List jobs by state (pending, processing, completed, failed, dead, blocked, cancelled)",
+	Long: `Display jobs in the queue with optional output display.
+
+Results come one page at a time (--limit, default 100). When more jobs match,
+the next page's --cursor is printed after the list (on stderr with --output).
+
+Examples:
+  queuectl list --state pending,failed --queue emails
+  queuectl list --since 2h --command-contains backup
+  queuectl list --since 2025-11-01T00:00:00Z --until 2025-11-02T00:00:00Z --time-field updated
+  queuectl list --min-attempts 2 --sort oldest
+  queuectl list --limit 50 --cursor <cursor from the previous page>`,
 	Run: func(cmd *cobra.Command, args []string) {
-		CommonInit()
-		db, err := store.InitDB()
-		if err != nil {
-			log.Fatal("DB init failed:", err)
+		if listLimit <= 0 {
+			log.Fatal("--limit must be positive")
 		}
-		repo := store.NewJobRepo(db)
+		CommonInit()
 
-		var states []job.JobState
-		if stateFilter != "" {
-			states = append(states, job.JobState(stateFilter))
+		filter := store.JobFilter{
+			Queue:           queueFilter,
+			TimeField:       store.TimeField(listTimeField),
+			CommandContains: commandContains,
+			MinAttempts:     minAttempts,
+			Limit:           listLimit,
+			Cursor:          listCursor,
+		}
+		for _, s := range stateFilter {
+			filter.States = append(filter.States, job.JobState(s))
+		}
+		if cmd.Flags().Changed("priority") {
+			filter.Priority = &priorityFilter
+		}
+		var err error
+		if filter.Since, err = parseTimeFlag(listSince); err != nil {
+			log.Fatalf("Invalid --since: %v", err)
+		}
+		if filter.Until, err = parseTimeFlag(listUntil); err != nil {
+			log.Fatalf("Invalid --until: %v", err)
+		}
+		if err := applySort(&filter, listSort); err != nil {
+			log.Fatalf("Invalid --sort: %v", err)
 		}
 
-		jobs, err := repo.ListJobs(states, 100, 0, true)
+		jobs, err := repo.ListJobs(filter)
 		if err != nil {
 			log.Fatal("Failed to list jobs:", err)
 		}
+		next := nextCursor(jobs, listLimit)
+
+		if printStructured(jobs) {
+			if next != "" {
+				fmt.Fprintf(os.Stderr, "next cursor: %s\n", next)
+			}
+			return
+		}
 
-		fmt.Printf("Listing jobs (state=%v):\n", stateFilter)
+		fmt.Printf("Listing jobs (state=%v, queue=%v):\n", strings.Join(stateFilter, ","), queueFilter)
 		for _, j := range jobs {
-			fmt.Printf("- [%s] %s | Attempts: %d/%d | State: %s\n",
-				j.ID, j.Command, j.Attempts, j.MaxRetries, j.State)
+			fmt.Printf("- [%s] %s | Queue: %s | Attempts: %d/%d | State: %s",
+				j.ID, j.CommandLine(), j.Queue, j.Attempts, j.MaxRetries, j.State)
+			if j.ExitCode != nil {
+				fmt.Printf(" | Exit: %d", *j.ExitCode)
+			}
+			if len(j.DependsOn) > 0 {
+				fmt.Printf(" | Depends on: %v", j.DependsOn)
+			}
+			fmt.Println()
 			if showOutput && j.Output != "" {
 				fmt.Printf("  Output:\n%s\n", j.Output)
 			}
 		}
+		if next != "" {
+			fmt.Printf("More jobs match; next page: --cursor %s\n", next)
+		}
 	},
 }
 
+// applySort sets the order of f from a --sort value.
+func applySort(f *store.JobFilter, sort string) error {
+	switch sort {
+	case "priority":
+		f.Sort, f.NewestFirst = store.SortPriority, true
+	case "newest":
+		f.Sort, f.NewestFirst = store.SortCreated, true
+	case "oldest":
+		f.Sort = store.SortCreated
+	default:
+		return fmt.Errorf("unknown sort %q (want priority, newest or oldest)", sort)
+	}
+	return nil
+}
+
+// parseTimeFlag reads an RFC3339 timestamp, or a duration meaning that long
+// ago (e.g. "2h"). An empty value is the zero time.
+func parseTimeFlag(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// nextCursor returns the --cursor of the page after jobs, or "" when a page
+// of limit jobs came back short and so was the last.
+func nextCursor(jobs []job.Job, limit int32) string {
+	if len(jobs) == 0 || len(jobs) < int(limit) {
+		return ""
+	}
+	return store.JobCursor(&jobs[len(jobs)-1])
+}
+
 func init() {
-	listCmd.Flags().StringVarP(&stateFilter, "state", "s", "", "filter by job state")
+	listCmd.Flags().StringSliceVarP(&stateFilter, "state", "s", nil, "filter by job state (comma-separated for several)")
+	listCmd.Flags().StringVar(&queueFilter, "queue", "", "filter by queue name")
 	listCmd.Flags().BoolVarP(&showOutput, "show-output", "o", false, "display job output") // ✅ add this line
+	listCmd.Flags().Int32Var(&listLimit, "limit", 100, "maximum number of jobs to show")
+	listCmd.Flags().StringVar(&listCursor, "cursor", "", "continue after the last job of a previous page")
+	listCmd.Flags().StringVar(&listSince, "since", "", "only jobs at or after this time (RFC3339, or a duration ago such as 2h)")
+	listCmd.Flags().StringVar(&listUntil, "until", "", "only jobs before this time (RFC3339, or a duration ago)")
+	listCmd.Flags().StringVar(&listTimeField, "time-field", "created", "timestamp --since/--until apply to: created or updated")
+	listCmd.Flags().StringVar(&commandContains, "command-contains", "", "only jobs whose command contains this text (ignoring case)")
+	listCmd.Flags().Int32Var(&minAttempts, "min-attempts", 0, "only jobs with at least this many failed attempts")
+	listCmd.Flags().IntVar(&priorityFilter, "priority", 0, "only jobs with exactly this priority")
+	listCmd.Flags().StringVar(&listSort, "sort", "priority", "order: priority (newest first within a priority), newest or oldest")
 	rootCmd.AddCommand(listCmd)
 }
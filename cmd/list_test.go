@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"testing"
+
+	"queuectl.backend/internal/job"
+)
+
+func TestNextCursorOnlyForFullPages(t *testing.T) {
+	page := []job.Job{{ID: "a"}, {ID: "b"}}
+	if c := nextCursor(page, 3); c != "" {
+		t.Fatalf("short page got cursor %q", c)
+	}
+	if c := nextCursor(nil, 3); c != "" {
+		t.Fatalf("empty page got cursor %q", c)
+	}
+	if c := nextCursor(page, 2); c == "" {
+		t.Fatal("full page got no cursor")
+	}
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/store"
+)
+
+var (
+	logsFollow     bool
+	logsStderr     bool
+	logsAttempt    int
+	logsTimestamps bool
+)
+
+// logsPollInterval is how often a followed log is checked for new output.
+const logsPollInterval = 500 * time.Millisecond
+
+// logTimeFormat is RFC 3339 with fixed-width milliseconds, so timestamped
+// lines stay aligned.
+const logTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+var logsCmd = &cobra.Command{
+	Use:   "logs [job-id]",
+	Short: "Print a job's output, optionally following it while it runs",
+	Long: `Print the stdout (or with --stderr, the stderr) of a job's latest attempt.
+
+With --follow the command keeps printing new output as the worker writes it
+(about once a second) and moves on to later attempts when the job is retried,
+until the job completes, dies or is cancelled. With --attempt it stays on that
+attempt and stops when it ends.
+
+Examples:
+  queuectl logs job-123
+  queuectl logs job-123 --follow --timestamps
+  queuectl logs job-123 --stderr --attempt 2`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		CommonInit()
+
+		j, err := repo.Get(args[0])
+		if errors.Is(err, store.ErrNotFound) {
+			log.Fatalf("Job %s not found", args[0])
+		} else if err != nil {
+			log.Fatalf("Failed to fetch job: %v", err)
+		}
+
+		q, err := logQuery(j.ID, logsAttempt, logsStderr)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		p := &logPrinter{w: os.Stdout, timestamps: logsTimestamps, attempt: q.Attempt}
+		if !logsFollow {
+			chunks, err := repo.ReadLogs(q)
+			if err != nil {
+				log.Fatalf("Failed to fetch output: %v", err)
+			}
+			err = p.print(chunks)
+			p.flush()
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		err = store.FollowLogs(ctx, repo, q, logsPollInterval, p.print)
+		p.flush()
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Fatalf("Failed to follow output: %v", err)
+		}
+	},
+}
+
+// logQuery selects one stream of a job's output: the given attempt, or from
+// the latest attempt on.
+func logQuery(jobID string, attempt int, stderr bool) (store.LogQuery, error) {
+	q := store.LogQuery{JobID: jobID, Attempt: attempt, Stream: job.StreamStdout}
+	if stderr {
+		q.Stream = job.StreamStderr
+	}
+	if attempt < 0 {
+		return q, fmt.Errorf("invalid attempt %d", attempt)
+	}
+	if attempt == 0 {
+		attempts, err := repo.ListAttempts(jobID)
+		if err != nil {
+			return q, fmt.Errorf("failed to fetch attempts: %w", err)
+		}
+		if len(attempts) > 0 {
+			q.SinceAttempt = attempts[len(attempts)-1].Number
+		}
+	}
+	return q, nil
+}
+
+// logPrinter writes log chunks as lines, optionally timestamped, with a
+// header whenever the output moves on to another attempt. Writers with a
+// Flush method, such as http.ResponseWriter, are flushed after each batch.
+type logPrinter struct {
+	w          io.Writer
+	timestamps bool
+	// attempt is the attempt printed last (or asked for); 0 before any.
+	attempt  int
+	splitter job.LineSplitter
+	err      error
+}
+
+func (p *logPrinter) print(chunks []job.LogChunk) error {
+	for _, c := range chunks {
+		if c.Attempt != p.attempt {
+			p.flush()
+			if p.attempt != 0 || c.Attempt > 1 {
+				p.header(c.Attempt)
+			}
+			p.attempt = c.Attempt
+		}
+		for _, line := range p.splitter.Add(&c) {
+			p.line(line)
+		}
+	}
+	if f, ok := p.w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+	return p.err
+}
+
+func (p *logPrinter) header(attempt int) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, "==> attempt %d <==\n", attempt)
+	}
+}
+
+func (p *logPrinter) flush() {
+	for _, line := range p.splitter.Flush() {
+		p.line(line)
+	}
+}
+
+func (p *logPrinter) line(l job.LogLine) {
+	if p.err != nil {
+		return
+	}
+	if p.timestamps {
+		_, p.err = fmt.Fprintf(p.w, "%s %s\n", l.Time.Local().Format(logTimeFormat), l.Text)
+		return
+	}
+	_, p.err = fmt.Fprintln(p.w, l.Text)
+}
+
+func init() {
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "keep printing new output until the job finishes")
+	logsCmd.Flags().BoolVar(&logsStderr, "stderr", false, "print stderr instead of stdout")
+	logsCmd.Flags().IntVar(&logsAttempt, "attempt", 0, "print this attempt instead of the latest")
+	logsCmd.Flags().BoolVarP(&logsTimestamps, "timestamps", "t", false, "prefix each line with the time it was written")
+	rootCmd.AddCommand(logsCmd)
+}
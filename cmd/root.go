@@ -2,21 +2,90 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
+	"queuectl.backend/internal/output"
+	"queuectl.backend/internal/store"
 )
 
+// dbURL selects the database for every subcommand: a SQLite file path or a
+// postgres:// DSN. Empty means QUEUECTL_DB, then store.DefaultPath().
+var dbURL string
+
+// outputFormat is the global --output format; empty keeps each command's own
+// human-readable text.
+var outputFormat string
+
+// dbEnv names the environment variable consulted when --db is not given.
+const dbEnv = "QUEUECTL_DB"
+
 var rootCmd = &cobra.Command{
 	Use:   "queuectl",
 	Short: "queuectl - a CLI background job manager",
 	Long: `queuectl lets you enqueue and manage background jobs with
 workers, retries, and a dead-letter queue.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		_, err := output.Parse(outputFormat)
+		return err
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Welcome to queuectl 🎯")
 	},
 }
 
+func init() {
+	rootCmd.PersistentFlags().StringVar(&dbURL, "db", "", "database to use: a SQLite file path or a postgres:// DSN (default $"+dbEnv+", then $XDG_DATA_HOME/queuectl/queue.db, with XDG_DATA_HOME defaulting to ~/.local/share)")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", "", "output format for read commands: json, yaml, table or csv (default human-readable text)")
+}
+
+// printStructured writes v in the --output format and reports whether it did;
+// commands print their usual text when it returns false.
+func printStructured(v any) bool {
+	f, err := output.Parse(outputFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if f == output.Text {
+		return false
+	}
+	if err := output.Write(os.Stdout, f, v); err != nil {
+		log.Fatalf("Failed to write %s output: %v", f, err)
+	}
+	return true
+}
+
+// databaseDSN resolves the database every command should use: the --db flag,
+// then the QUEUECTL_DB environment variable, then the per-user default.
+func databaseDSN() string {
+	if dbURL != "" {
+		return dbURL
+	}
+	if env := os.Getenv(dbEnv); env != "" {
+		return env
+	}
+	path := store.DefaultPath()
+	warnLegacyDB(path)
+	return path
+}
+
+// legacyDB is where queuectl kept its database before the per-user default.
+const legacyDB = "queue.db"
+
+// warnLegacyDB points out a queue.db in the working directory, which older
+// versions used and the default no longer reads.
+func warnLegacyDB(defaultPath string) {
+	if _, err := os.Stat(legacyDB); err != nil {
+		return
+	}
+	if abs, err := filepath.Abs(legacyDB); err == nil && abs == defaultPath {
+		return
+	}
+	log.Printf("⚠️ Found ./%s, which is no longer used by default; using %s. Pass --db %s or set $%s to keep using it.", legacyDB, defaultPath, legacyDB, dbEnv)
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
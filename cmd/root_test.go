@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWarnLegacyDB(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	warnLegacyDB(filepath.Join(dir, "data", "queue.db"))
+	if logged.Len() != 0 {
+		t.Fatalf("warned without ./queue.db: %s", &logged)
+	}
+
+	if err := os.WriteFile("queue.db", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	warnLegacyDB(filepath.Join(dir, "data", "queue.db"))
+	if !strings.Contains(logged.String(), "--db queue.db") {
+		t.Fatalf("no warning for ./queue.db: %q", &logged)
+	}
+
+	logged.Reset()
+	warnLegacyDB(filepath.Join(dir, "queue.db"))
+	if logged.Len() != 0 {
+		t.Fatalf("warned about the default database itself: %s", &logged)
+	}
+}
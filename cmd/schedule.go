@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"queuectl.backend/internal/schedule"
+)
+
+var (
+	scheduleCron string
+	scheduleTZ   string
+	scheduleID   string
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage recurring jobs driven by cron expressions",
+	Long: `Manage recurring jobs. A running scheduler (inside "worker start" or
+"queuectl scheduler") enqueues one job from the template at every cron tick.
+
+Examples:
+  queuectl schedule add --cron "*/5 * * * *" '{"command":"./sync.sh"}'
+  queuectl schedule add --id nightly-report --cron "0 2 * * *" --tz Europe/Berlin '{"command":"./report.sh","queue":"reports"}'
+  queuectl schedule list
+  queuectl schedule pause nightly-report`,
+}
+
+var scheduleAddCmd = &cobra.Command{
+	Use:   "add [job-json]",
+	Short: "Add a recurring job",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		CommonInit()
+
+		s := schedule.Schedule{
+			ID:       scheduleID,
+			Cron:     scheduleCron,
+			Timezone: scheduleTZ,
+			Job:      args[0],
+		}
+		if s.ID == "" {
+			s.ID = fmt.Sprintf("sched-%d", time.Now().UnixNano())
+		}
+		if err := schedule.NewRepository(repo).Add(&s); err != nil {
+			log.Fatalf("Failed to add schedule: %v", err)
+		}
+		fmt.Printf("Schedule %s added (%s %s), first run at %s\n",
+			s.ID, s.Cron, s.Timezone, s.NextRunAt.Format(time.RFC3339))
+	},
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recurring jobs",
+	Run: func(cmd *cobra.Command, args []string) {
+		CommonInit()
+
+		items, err := schedule.NewRepository(repo).All()
+		if err != nil {
+			log.Fatalf("Failed to list schedules: %v", err)
+		}
+		if printStructured(items) {
+			return
+		}
+		if len(items) == 0 {
+			fmt.Println("No schedules defined.")
+			return
+		}
+		fmt.Println("Schedules:")
+		for _, s := range items {
+			status := "active"
+			if s.Paused {
+				status = "paused"
+			}
+			last := "-"
+			if s.LastRunAt != nil {
+				last = s.LastRunAt.Format(time.RFC3339)
+			}
+			fmt.Printf("- %s | %s (%s) | %s | next: %s | last: %s | job: %s\n",
+				s.ID, s.Cron, s.Timezone, status, s.NextRunAt.Format(time.RFC3339), last, s.Job)
+		}
+	},
+}
+
+// scheduleStateCmd builds the pause and resume subcommands.
+func scheduleStateCmd(use, short string, paused bool) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " <schedule-id>",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			CommonInit()
+			if err := schedule.NewRepository(repo).SetPaused(args[0], paused); err != nil {
+				log.Fatalf("Failed to %s schedule: %v", use, err)
+			}
+			fmt.Printf("Schedule %s %sd\n", args[0], use)
+		},
+	}
+}
+
+var scheduleDeleteCmd = &cobra.Command{
+	Use:   "delete <schedule-id>",
+	Short: "Delete a recurring job (jobs it already enqueued are kept)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		CommonInit()
+		if err := schedule.NewRepository(repo).Delete(args[0]); err != nil {
+			log.Fatalf("Failed to delete schedule: %v", err)
+		}
+		fmt.Printf("Schedule %s deleted\n", args[0])
+	},
+}
+
+func init() {
+	scheduleAddCmd.Flags().StringVar(&scheduleCron, "cron", "", `cron expression, e.g. "*/5 * * * *" or "@hourly"`)
+	scheduleAddCmd.Flags().StringVar(&scheduleTZ, "tz", "UTC", "IANA timezone the cron expression is evaluated in (e.g., Europe/Berlin)")
+	scheduleAddCmd.Flags().StringVar(&scheduleID, "id", "", "schedule ID (default: generated)")
+	scheduleAddCmd.MarkFlagRequired("cron")
+
+	scheduleCmd.AddCommand(scheduleAddCmd)
+	scheduleCmd.AddCommand(scheduleListCmd)
+	scheduleCmd.AddCommand(scheduleStateCmd("pause", "Stop a schedule from enqueueing jobs", true))
+	scheduleCmd.AddCommand(scheduleStateCmd("resume", "Resume a paused schedule from its next tick", false))
+	scheduleCmd.AddCommand(scheduleDeleteCmd)
+	rootCmd.AddCommand(scheduleCmd)
+}
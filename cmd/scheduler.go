@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"queuectl.backend/internal/schedule"
+)
+
+var schedulerInterval time.Duration
+
+// schedulerCmd runs only the scheduler loop, for setups that keep it apart
+// from the workers.
+var schedulerCmd = &cobra.Command{
+	Use:   "scheduler",
+	Short: "Run the scheduler that enqueues recurring jobs",
+	Long: `Run the scheduler loop on its own. Workers started with "worker start"
+already run one unless --scheduler=false is given; running several is safe
+because every cron tick is enqueued at most once.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		CommonInit()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		schedule.Run(ctx, schedule.NewRepository(repo), schedulerInterval)
+	},
+}
+
+func init() {
+	schedulerCmd.Flags().DurationVar(&schedulerInterval, "interval", 5*time.Second, "how often to check for due schedules")
+	rootCmd.AddCommand(schedulerCmd)
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/output"
+	"queuectl.backend/internal/store"
+)
+
+var showJSON bool
+
+var showCmd = &cobra.Command{
+	Use:   "show [job-id]",
+	Short: "Show every detail of a single job",
+	Long: `Print every field of a job, including its schedule, timestamps, last error
+and full output. Exits with status 1 when the job does not exist.
+
+Examples:
+  queuectl show job-123
+  queuectl show job-123 --json | jq .state`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		CommonInit()
+
+		j, err := repo.Get(args[0])
+		if errors.Is(err, store.ErrNotFound) {
+			log.Fatalf("Job %s not found", args[0])
+		} else if err != nil {
+			log.Fatalf("Failed to fetch job: %v", err)
+		}
+
+		if showJSON {
+			outputFormat = string(output.JSON)
+		}
+		if printStructured(j) {
+			return
+		}
+		printJob(j)
+	},
+}
+
+// printJob writes every field of j as aligned "Name: value" lines.
+func printJob(j *job.Job) {
+	row := func(name string, value any) {
+		fmt.Printf("%-17s %v\n", name+":", value)
+	}
+	optTime := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Local().Format(time.RFC3339)
+	}
+	optString := func(s *string) string {
+		if s == nil || strings.TrimSpace(*s) == "" {
+			return "-"
+		}
+		return strings.TrimSpace(*s)
+	}
+
+	row("ID", j.ID)
+	row("Command", j.CommandLine())
+	row("Exec mode", j.Mode())
+	if j.Cwd != "" {
+		row("Working dir", j.Cwd)
+	}
+	if len(j.Env) > 0 {
+		row("Env", strings.Join(slices.Sorted(maps.Keys(j.Env)), ", "))
+	}
+	if j.Stdin != "" {
+		row("Stdin", fmt.Sprintf("%d bytes", len(j.Stdin)))
+	}
+	row("State", j.State)
+	row("Queue", j.Queue)
+	row("Priority", j.Priority)
+	row("Attempts", fmt.Sprintf("%d/%d", j.Attempts, j.MaxRetries))
+	if j.ExitCode != nil {
+		row("Exit code", *j.ExitCode)
+	} else {
+		row("Exit code", "-")
+	}
+	row("Run at", optTime(j.RunAt))
+	row("Duration", fmt.Sprintf("%.2fs", j.Duration))
+	if j.Timeout > 0 {
+		row("Timeout", time.Duration(j.Timeout))
+	}
+	if j.Backoff != nil {
+		b, _ := json.Marshal(j.Backoff)
+		row("Backoff", string(b))
+	}
+	if len(j.RetryOnExitCodes) > 0 {
+		row("Retry on exit", j.RetryOnExitCodes)
+	}
+	if len(j.FailFastExitCodes) > 0 {
+		row("Fail fast exit", j.FailFastExitCodes)
+	}
+	if len(j.DependsOn) > 0 {
+		row("Depends on", strings.Join(j.DependsOn, ", "))
+		onDead := j.OnParentDead
+		if onDead == "" {
+			onDead = job.OnParentDeadCascade
+		}
+		row("On parent dead", onDead)
+	}
+	if j.IdempotencyKey != "" {
+		scope := j.UniqueScope
+		if scope == job.UniqueWindow {
+			scope = fmt.Sprintf("%s %s", scope, time.Duration(j.UniqueFor))
+		}
+		row("Idempotency key", fmt.Sprintf("%s (%s)", j.IdempotencyKey, scope))
+	}
+	row("Worker", optString(j.WorkerID))
+	row("Lease expires", optTime(j.LeaseExpiresAt))
+	if j.CancelRequested {
+		row("Cancel requested", "yes")
+	}
+	row("Created at", j.CreatedAt.Local().Format(time.RFC3339))
+	row("Updated at", j.UpdatedAt.Local().Format(time.RFC3339))
+	row("Last error", optString(j.LastError))
+
+	output := strings.TrimRight(j.Output, "\n")
+	if strings.TrimSpace(output) == "" {
+		row("Output", "-")
+	} else {
+		fmt.Printf("Output (end of the last run; full output: queuectl attempts %s -o):\n%s\n", j.ID, output)
+	}
+}
+
+func init() {
+	showCmd.Flags().BoolVar(&showJSON, "json", false, "print the job as JSON (same as --output json)")
+	rootCmd.AddCommand(showCmd)
+}
@@ -15,17 +15,24 @@ var statsCmd = &cobra.Command{
 	Long:  "Displays total jobs, per-state counts, average duration, and retry statistics.",
 	Run: func(cmd *cobra.Command, args []string) {
 		CommonInit()
-		db, err := store.InitDB()
-		if err != nil {
-			log.Fatal("DB init failed:", err)
-		}
-		repo := store.NewJobRepo(db)
 
 		summary, err := repo.JobMetrics()
 		if err != nil {
 			log.Fatalf("Failed to get metrics: %v", err)
 		}
 
+		// With --by-queue, structured output is one row per queue.
+		byQueue, _ := cmd.Flags().GetBool("by-queue")
+		var queues []store.QueueSummary
+		if byQueue {
+			queues = queueMetrics()
+			if printStructured(queues) {
+				return
+			}
+		} else if printStructured(summary) {
+			return
+		}
+
 		fmt.Println("\n📊 Queue Metrics Summary")
 		fmt.Println("----------------------------")
 		fmt.Printf("Total Jobs:       %d\n", summary.Total)
@@ -34,12 +41,41 @@ var statsCmd = &cobra.Command{
 		fmt.Printf("Completed:        %d\n", summary.Completed)
 		fmt.Printf("Failed:           %d\n", summary.Failed)
 		fmt.Printf("Dead (DLQ):       %d\n", summary.Dead)
+		fmt.Printf("Blocked:          %d\n", summary.Blocked)
+		fmt.Printf("Cancelled:        %d\n", summary.Cancelled)
 		fmt.Printf("Avg Duration:     %.2fs\n", summary.AvgDuration)
 		fmt.Printf("Avg Retries/job:  %.2f\n", summary.AvgRetries)
 		fmt.Println("----------------------------")
+
+		if byQueue {
+			printQueueBreakdown(queues)
+		}
 	},
 }
 
+// queueMetrics fetches per-state job counts for every queue.
+func queueMetrics() []store.QueueSummary {
+	queues, err := repo.QueueMetrics()
+	if err != nil {
+		log.Fatalf("Failed to get queue metrics: %v", err)
+	}
+	return queues
+}
+
+// printQueueBreakdown prints per-state job counts for every queue.
+func printQueueBreakdown(queues []store.QueueSummary) {
+	if len(queues) == 0 {
+		fmt.Println("No queues yet.")
+		return
+	}
+
+	fmt.Printf("\n%-16s %8s %10s %9s %6s %5s %7s %9s %6s\n", "Queue", "Pending", "Processing", "Completed", "Failed", "Dead", "Blocked", "Cancelled", "Total")
+	for _, q := range queues {
+		fmt.Printf("%-16s %8d %10d %9d %6d %5d %7d %9d %6d\n", q.Queue, q.Pending, q.Processing, q.Completed, q.Failed, q.Dead, q.Blocked, q.Cancelled, q.Total)
+	}
+}
+
 func init() {
+	statsCmd.Flags().Bool("by-queue", false, "also break the counts down by queue")
 	rootCmd.AddCommand(statsCmd)
 }
@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"log"
 
-	"queuectl.backend/internal/job"
 	"github.com/spf13/cobra"
+	"queuectl.backend/internal/store"
 )
 
 // statusCmd gives a quick summary of queue state.
@@ -16,38 +16,40 @@ var statusCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		CommonInit()
 
-		states := []job.JobState{
-			job.StatePending,
-			job.StateProcessing,
-			job.StateCompleted,
-			job.StateFailed,
-			job.StateDead,
+		summary, err := repo.JobMetrics()
+		if err != nil {
+			log.Fatalf("Failed to count jobs: %v", err)
 		}
 
-		counts := make(map[job.JobState]int64)
-		var total int64
-
-		for _, state := range states {
-			var count int64
-			if err := repo.DB().Model(&job.Job{}).
-				Where("state = ?", state).
-				Count(&count).Error; err != nil {
-				log.Fatalf("Failed to count jobs for state %s: %v", state, err)
+		// With --by-queue, structured output is one row per queue.
+		byQueue, _ := cmd.Flags().GetBool("by-queue")
+		var queues []store.QueueSummary
+		if byQueue {
+			queues = queueMetrics()
+			if printStructured(queues) {
+				return
 			}
-			counts[state] = count
-			total += count
+		} else if printStructured(summary) {
+			return
 		}
 
 		fmt.Println("Job Queue Status:")
-		fmt.Printf("Total Jobs: %d\n", total)
-		fmt.Printf("Pending: %d\n", counts[job.StatePending])
-		fmt.Printf("Processing: %d\n", counts[job.StateProcessing])
-		fmt.Printf("Completed: %d\n", counts[job.StateCompleted])
-		fmt.Printf("Failed: %d\n", counts[job.StateFailed])
-		fmt.Printf("Dead (DLQ): %d\n", counts[job.StateDead])
+		fmt.Printf("Total Jobs: %d\n", summary.Total)
+		fmt.Printf("Pending: %d\n", summary.Pending)
+		fmt.Printf("Processing: %d\n", summary.Processing)
+		fmt.Printf("Completed: %d\n", summary.Completed)
+		fmt.Printf("Failed: %d\n", summary.Failed)
+		fmt.Printf("Dead (DLQ): %d\n", summary.Dead)
+		fmt.Printf("Blocked: %d\n", summary.Blocked)
+		fmt.Printf("Cancelled: %d\n", summary.Cancelled)
+
+		if byQueue {
+			printQueueBreakdown(queues)
+		}
 	},
 }
 
 func init() {
+	statusCmd.Flags().Bool("by-queue", false, "also break the counts down by queue")
 	rootCmd.AddCommand(statusCmd)
 }
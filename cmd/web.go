@@ -1,13 +1,20 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/spf13/cobra"
+	"queuectl.backend/internal/config"
 	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/metrics"
 	"queuectl.backend/internal/store"
 )
 
@@ -16,38 +23,278 @@ var webCmd = &cobra.Command{
 	Short: "Start a simple web dashboard for monitoring the job queue",
 	Run: func(cmd *cobra.Command, args []string) {
 		CommonInit()
-		db, err := store.InitDB()
-		if err != nil {
-			log.Fatalf("Failed to init DB: %v", err)
-		}
-		repo := store.NewJobRepo(db)
 
-		tmpl := template.Must(template.New("dashboard").Parse(htmlTemplate))
+		tmpl := dashboardTemplates()
+		http.HandleFunc("/", serveDashboard(tmpl))
 
-		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			stats, _ := repo.JobMetrics()
-			jobs, _ := repo.ListJobs(nil, 100, 0, true)
+		http.HandleFunc("/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+			j, err := repo.Get(r.PathValue("id"))
+			if errors.Is(err, store.ErrNotFound) {
+				http.NotFound(w, r)
+				return
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			attempts, _ := repo.ListAttempts(j.ID)
+			// The last attempt is shown in full, earlier ones by their preview.
+			var stdout, stderr string
+			if n := len(attempts); n > 0 {
+				last := attempts[n-1]
+				stdout, _ = attemptOutput(j.ID, last.Number, job.StreamStdout, last.Stdout)
+				stderr, _ = attemptOutput(j.ID, last.Number, job.StreamStderr, last.Stderr)
+			}
 
 			data := struct {
-				Metrics store.MetricsSummary
-				Jobs    []job.Job
-			}{stats, jobs}
+				Job      *job.Job
+				Attempts []job.Attempt
+				Stdout   string
+				Stderr   string
+				Role     config.Role
+				CSRF     string
+				Notice   string
+			}{j, attempts, stdout, stderr, requestRole(r), csrfToken(w, r), takeNotice(w, r)}
 
 			w.Header().Set("Content-Type", "text/html")
-			tmpl.Execute(w, data)
+			tmpl.ExecuteTemplate(w, "job", data)
+		})
+
+		// Plain-text output of a job, streamed while it runs like
+		// "queuectl logs --follow". Query: stream=stderr, attempt=N,
+		// timestamps=1, follow=0 for what is stored so far.
+		http.HandleFunc("/jobs/{id}/logs", func(w http.ResponseWriter, r *http.Request) {
+			j, err := repo.Get(r.PathValue("id"))
+			if errors.Is(err, store.ErrNotFound) {
+				http.NotFound(w, r)
+				return
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			query := r.URL.Query()
+			attempt, _ := strconv.Atoi(query.Get("attempt"))
+			q, err := logQuery(j.ID, attempt, query.Get("stream") == job.StreamStderr)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			p := &logPrinter{w: w, timestamps: query.Get("timestamps") == "1", attempt: q.Attempt}
+			if query.Get("follow") == "0" {
+				chunks, err := repo.ReadLogs(q)
+				if err == nil {
+					p.print(chunks)
+				}
+			} else {
+				err = store.FollowLogs(r.Context(), repo, q, logsPollInterval, p.print)
+			}
+			p.flush()
+			if err != nil && r.Context().Err() == nil {
+				log.Printf("web: streaming output of job %s: %v", j.ID, err)
+			}
 		})
+		http.HandleFunc("GET /events", serveEvents)
+		http.HandleFunc("POST /actions/{action}", serveAction)
+		registerAPI()
+
+		reg := metrics.NewRegistry()
+		store.RegisterMetrics(reg, repo)
+		http.Handle("GET /metrics", reg)
 
-		fmt.Println("✅ Web dashboard running at: http://localhost:8080")
-		log.Fatal(http.ListenAndServe(":8080", nil))
+		if (webTLSCert == "") != (webTLSKey == "") {
+			log.Fatalf("--tls-cert and --tls-key must be given together")
+		}
+		auth := newWebAuth()
+		settings, err := auth.load()
+		if err != nil {
+			log.Fatalf("Failed to read web credentials: %v", err)
+		}
+		if !settings.Modes.Enabled() {
+			if !loopbackAddr(webAddr) && !webInsecure {
+				log.Fatalf("Refusing to serve %s without authentication: set web-auth (see \"queuectl auth\") or pass --insecure", webAddr)
+			}
+			log.Printf("⚠️ Authentication is off: everyone who can reach %s may read and change jobs (see \"queuectl auth\")", webAddr)
+		} else if webTLSCert == "" {
+			log.Printf("⚠️ Serving %s auth without TLS: credentials cross the network in the clear", settings.Modes)
+		}
+
+		// Browsers send basic auth credentials on cross-site requests too, so
+		// refuse those that change anything, for the API as for the forms.
+		handler := auth.Wrap(http.NewCrossOriginProtection().Handler(http.DefaultServeMux))
+		server := &http.Server{Addr: webAddr, Handler: handler}
+		base := webBaseURL(webAddr, webTLSCert != "")
+		fmt.Printf("✅ Web dashboard running at: %s\n", base)
+		fmt.Printf("   JSON API at: %s/api/v1\n", base)
+		fmt.Printf("   Prometheus metrics at: %s/metrics\n", base)
+		if webTLSCert != "" {
+			log.Fatal(server.ListenAndServeTLS(webTLSCert, webTLSKey))
+		}
+		log.Fatal(server.ListenAndServe())
 	},
 }
 
-const htmlTemplate = `
-<!DOCTYPE html>
-<html lang="en">
-<head>
-<meta charset="UTF-8">
-<title>QueueCTL Dashboard</title>
+// dashboardTemplates parses the dashboard and job page templates.
+func dashboardTemplates() *template.Template {
+	funcs := template.FuncMap{
+		// actions returns the row actions of j a user with role may take.
+		"actions": func(role config.Role, j *job.Job) []*dashboardAction {
+			return allowedActions(role, rowActions(j))
+		},
+	}
+	tmpl := template.Must(template.New("style").Funcs(funcs).Parse(styleTemplate))
+	template.Must(tmpl.New("dashboard").Parse(htmlTemplate))
+	template.Must(tmpl.New("job").Parse(jobTemplate))
+	return tmpl
+}
+
+// serveDashboard renders the job listing with tmpl. Query: queue, state
+// (repeated) and cursor.
+func serveDashboard(tmpl *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		role := requestRole(r)
+		live := liveConfig{
+			Queue:     query.Get("queue"),
+			States:    []job.JobState{},
+			FirstPage: query.Get("cursor") == "",
+			PageSize:  webPageSize,
+			Actions:   allowedActions(role, dashboardActions),
+			// Taken before reading, so live updates replay whatever
+			// changes while the page is built.
+			Since: time.Now().UTC().Format(time.RFC3339Nano),
+		}
+		for _, s := range query["state"] {
+			live.States = append(live.States, job.JobState(s))
+		}
+		stats, err := repo.JobMetrics()
+		if err != nil {
+			dashboardError(w, err)
+			return
+		}
+		queues, err := repo.QueueMetrics()
+		if err != nil {
+			dashboardError(w, err)
+			return
+		}
+		jobs, err := repo.ListJobs(store.JobFilter{
+			Queue:       live.Queue,
+			States:      live.States,
+			Limit:       webPageSize,
+			NewestFirst: true,
+			Cursor:      query.Get("cursor"),
+		})
+		if err != nil {
+			dashboardError(w, err)
+			return
+		}
+		next := ""
+		if len(jobs) == webPageSize {
+			query.Set("cursor", store.JobCursor(&jobs[len(jobs)-1]))
+			next = "/?" + query.Encode()
+		}
+		var states []stateOption
+		for _, s := range allStates {
+			states = append(states, stateOption{s, slices.Contains(live.States, s)})
+		}
+
+		data := struct {
+			Metrics store.MetricsSummary
+			Queues  []store.QueueSummary
+			Queue   string
+			States  []stateOption
+			Jobs    []job.Job
+			Next    string
+			Live    liveConfig
+			Role    config.Role
+			Actions []*dashboardAction
+			CSRF    string
+			Notice  string
+			Return  string
+		}{stats, queues, live.Queue, states, jobs, next, live, role, live.Actions, csrfToken(w, r), takeNotice(w, r), r.URL.RequestURI()}
+
+		w.Header().Set("Content-Type", "text/html")
+		tmpl.ExecuteTemplate(w, "dashboard", data)
+	}
+}
+
+// dashboardError answers a dashboard request that failed with err: a bad
+// filter or cursor is the client's fault, anything else the server's.
+func dashboardError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, store.ErrInvalidFilter) {
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+var (
+	webAddr, webTLSCert, webTLSKey string
+	webInsecure                    bool
+)
+
+// loopbackAddr reports whether addr only accepts connections from this host.
+// An empty host listens on every interface.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// webBaseURL is the address the web server can be visited at.
+func webBaseURL(addr string, tls bool) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, ""
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	if port == "" {
+		return scheme + "://" + host
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// webPageSize is the number of jobs per dashboard page.
+const webPageSize = 100
+
+// allStates lists the job states in the order the dashboard shows them.
+var allStates = []job.JobState{
+	job.StatePending, job.StateProcessing, job.StateCompleted, job.StateFailed,
+	job.StateDead, job.StateBlocked, job.StateCancelled,
+}
+
+// stateOption is one state checkbox of the dashboard.
+type stateOption struct {
+	Name    job.JobState
+	Checked bool
+}
+
+// liveConfig tells the dashboard script which rows the page shows, so
+// events for other jobs are left out.
+type liveConfig struct {
+	Queue     string         `json:"queue"`
+	States    []job.JobState `json:"states"`
+	FirstPage bool           `json:"firstPage"`
+	PageSize  int            `json:"pageSize"`
+	Since     string         `json:"since"`
+	// Actions are the row actions, named in each job event's "actions".
+	Actions []*dashboardAction `json:"actions"`
+}
+
+const styleTemplate = `
 <style>
   body { font-family: Arial, sans-serif; background: #f8fafc; margin: 0; padding: 20px; }
   h1 { text-align: center; color: #333; }
@@ -59,47 +306,387 @@ const htmlTemplate = `
   .state-processing { color: blue; font-weight: bold; }
   .state-completed { color: green; font-weight: bold; }
   .state-failed, .state-dead { color: red; font-weight: bold; }
+  .state-blocked, .state-cancelled { color: gray; font-weight: bold; }
+  .attempt { background: #fff; padding: 10px; margin-top: 12px; border-radius: 6px; box-shadow: 0 0 5px rgba(0,0,0,0.1); }
+  pre { background: #f2f2f2; padding: 8px; white-space: pre-wrap; }
   .stats { display: flex; justify-content: space-around; margin-top: 20px; background: #fff; padding: 10px; border-radius: 6px; box-shadow: 0 0 5px rgba(0,0,0,0.1); }
+  .filters { margin-top: 20px; }
+  .filters label { margin-right: 10px; }
+  #live-status { color: gray; margin-left: 8px; }
+  tr.changed td { background: #fff4c2; transition: background 1s; }
+  .notice { background: #e8f1fb; border: 1px solid #9cc2ea; padding: 8px 12px; border-radius: 6px; }
+  .bulk { margin-top: 12px; }
+  td.actions { white-space: nowrap; }
+  td.actions button { font-size: 0.85em; }
 </style>
+`
+
+const htmlTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>QueueCTL Dashboard</title>
+{{template "style"}}
 </head>
 <body>
 <h1>QueueCTL Dashboard</h1>
+{{with .Notice}}<p class="notice">{{.}}</p>{{end}}
 
 <div class="stats">
-  <div><b>Total:</b> {{.Metrics.Total}}</div>
-  <div><b>Pending:</b> {{.Metrics.Pending}}</div>
-  <div><b>Processing:</b> {{.Metrics.Processing}}</div>
-  <div><b>Completed:</b> {{.Metrics.Completed}}</div>
-  <div><b>Failed:</b> {{.Metrics.Failed}}</div>
-  <div><b>DLQ:</b> {{.Metrics.Dead}}</div>
+  <div><b>Total:</b> <span data-stat="total">{{.Metrics.Total}}</span></div>
+  <div><b>Pending:</b> <span data-stat="pending">{{.Metrics.Pending}}</span></div>
+  <div><b>Processing:</b> <span data-stat="processing">{{.Metrics.Processing}}</span></div>
+  <div><b>Completed:</b> <span data-stat="completed">{{.Metrics.Completed}}</span></div>
+  <div><b>Failed:</b> <span data-stat="failed">{{.Metrics.Failed}}</span></div>
+  <div><b>DLQ:</b> <span data-stat="dead">{{.Metrics.Dead}}</span></div>
+  <div><b>Blocked:</b> <span data-stat="blocked">{{.Metrics.Blocked}}</span></div>
+  <div><b>Cancelled:</b> <span data-stat="cancelled">{{.Metrics.Cancelled}}</span></div>
 </div>
 
+<table id="queues"{{if not .Queues}} hidden{{end}}>
+  <thead>
+  <tr>
+    <th>Queue</th>
+    <th>Pending</th>
+    <th>Processing</th>
+    <th>Completed</th>
+    <th>Failed</th>
+    <th>DLQ</th>
+    <th>Blocked</th>
+    <th>Cancelled</th>
+    <th>Total</th>
+  </tr>
+  </thead>
+  <tbody>
+  {{range .Queues}}
+  <tr>
+    <td><a href="/?queue={{.Queue}}">{{.Queue}}</a></td>
+    <td>{{.Pending}}</td>
+    <td>{{.Processing}}</td>
+    <td>{{.Completed}}</td>
+    <td>{{.Failed}}</td>
+    <td>{{.Dead}}</td>
+    <td>{{.Blocked}}</td>
+    <td>{{.Cancelled}}</td>
+    <td>{{.Total}}</td>
+  </tr>
+  {{end}}
+  </tbody>
+</table>
+
+{{if .Queue}}<p>Showing jobs in queue <b>{{.Queue}}</b> — <a href="/">show all</a></p>{{end}}
+<form id="filters" class="filters" method="get" action="/">
+  {{if .Queue}}<input type="hidden" name="queue" value="{{.Queue}}">{{end}}
+  <b>State:</b>
+  {{range .States}}<label><input type="checkbox" name="state" value="{{.Name}}"{{if .Checked}} checked{{end}}> {{.Name}}</label>{{end}}
+  <noscript><button>Filter</button></noscript>
+  <button type="button" id="pause">Pause</button><span id="live-status"></span>
+</form>
+<form id="actions" method="post" action="/actions/cancel">
+<input type="hidden" name="csrf_token" value="{{.CSRF}}">
+<input type="hidden" name="return" value="{{.Return}}">
+{{if .Actions}}<div class="bulk">
+  <b>With selected:</b>
+  {{range .Actions}}{{if eq .Name "priority"}}<label>priority <input type="number" name="priority" value="0" size="4"></label>
+  <button formaction="/actions/priority">Set priority</button>{{else}}<button formaction="/actions/{{.Name}}" data-confirm="{{.Confirm}}">{{.Label}}</button>{{end}}
+  {{end}}
+</div>{{end}}
 <table>
+  <thead>
   <tr>
+    <th><input type="checkbox" id="select-all" title="Select all"></th>
     <th>ID</th>
     <th>Command</th>
+    <th>Queue</th>
     <th>State</th>
     <th>Priority</th>
     <th>Attempts</th>
+    <th>Exit</th>
     <th>Run At</th>
     <th>Duration (s)</th>
+    <th>Actions</th>
   </tr>
+  </thead>
+  <tbody id="jobs">
   {{range .Jobs}}
-  <tr>
-    <td>{{.ID}}</td>
-    <td>{{.Command}}</td>
+  <tr data-id="{{.ID}}" data-created="{{.CreatedAt.UnixMilli}}">
+    <td><input type="checkbox" name="id" value="{{.ID}}"></td>
+    <td><a href="/jobs/{{.ID}}">{{.ID}}</a></td>
+    <td>{{.CommandLine}}</td>
+    <td>{{.Queue}}</td>
     <td class="state-{{.State}}">{{.State}}</td>
     <td>{{.Priority}}</td>
     <td>{{.Attempts}} / {{.MaxRetries}}</td>
+    <td>{{if .ExitCode}}{{.ExitCode}}{{else}}-{{end}}</td>
     <td>{{if .RunAt}}{{.RunAt.Format "15:04:05"}}{{else}}-{{end}}</td>
     <td>{{printf "%.2f" .Duration}}</td>
+    <td class="actions">{{$id := .ID}}{{range actions $.Role .}}<button name="only" value="{{$id}}" formaction="/actions/{{.Name}}"{{if .Confirm}} data-confirm="{{.Confirm}}"{{else}} data-prompt="New priority"{{end}}>{{.Label}}</button> {{end}}</td>
   </tr>
   {{end}}
+  </tbody>
 </table>
+</form>
+{{if .Next}}<p><a href="{{.Next}}">Next page &rarr;</a></p>{{end}}
+<script>
+// Live updates: /events sends a "job" event for every changed job and a
+// "stats" event when the counters move; rows are updated in place.
+(function () {
+  var live = {{.Live}};
+  var rows = document.getElementById("jobs");
+  var status = document.getElementById("live-status");
+  var pause = document.getElementById("pause");
+  var lastEventId = live.since;
+  var source = null;
+
+  document.querySelectorAll("#filters input[type=checkbox]").forEach(function (box) {
+    box.addEventListener("change", function () { box.form.submit(); });
+  });
+
+  function cell(row, content, className) {
+    var td = row.insertCell();
+    if (content instanceof Node) {
+      td.appendChild(content);
+    } else {
+      td.textContent = content;
+    }
+    if (className) {
+      td.className = className;
+    }
+  }
+
+  function render(row, j) {
+    var selected = row.querySelector("input[name=id]:checked") !== null;
+    row.dataset.id = j.id;
+    row.dataset.created = Date.parse(j.created_at);
+    row.replaceChildren();
+    var box = document.createElement("input");
+    box.type = "checkbox";
+    box.name = "id";
+    box.value = j.id;
+    box.checked = selected;
+    cell(row, box);
+    var link = document.createElement("a");
+    link.href = "/jobs/" + encodeURIComponent(j.id);
+    link.textContent = j.id;
+    cell(row, link);
+    cell(row, j.command);
+    cell(row, j.queue);
+    cell(row, j.state, "state-" + j.state);
+    cell(row, j.priority);
+    cell(row, j.attempts + " / " + j.max_retries);
+    cell(row, j.exit_code == null ? "-" : j.exit_code);
+    cell(row, j.run_at ? new Date(j.run_at).toISOString().substr(11, 8) : "-");
+    cell(row, j.duration.toFixed(2));
+    var buttons = document.createElement("span");
+    live.actions.forEach(function (a) {
+      if (j.actions.indexOf(a.name) < 0) {
+        return;
+      }
+      var button = document.createElement("button");
+      button.name = "only";
+      button.value = j.id;
+      button.formAction = "/actions/" + a.name;
+      button.textContent = a.label;
+      if (a.confirm) {
+        button.dataset.confirm = a.confirm;
+      } else {
+        button.dataset.prompt = "New priority";
+      }
+      buttons.append(button, " ");
+    });
+    cell(row, buttons, "actions");
+  }
+
+  // Row buttons act on their own job, the others on the ticked rows. Ask
+  // before acting, or for the new priority of a single job.
+  document.getElementById("actions").addEventListener("submit", function (e) {
+    var button = e.submitter;
+    if (!button) {
+      return;
+    }
+    var form = e.currentTarget;
+    var target = button.name === "only" ? "job " + button.value :
+      form.querySelectorAll("input[name=id]:checked").length + " selected job(s)";
+    if (button.dataset.confirm && !confirm(button.dataset.confirm.replace("%s", target))) {
+      e.preventDefault();
+    } else if (button.dataset.prompt) {
+      var priority = prompt(button.dataset.prompt + " for " + target + ":", "0");
+      if (priority === null) {
+        e.preventDefault();
+      } else {
+        form.elements.priority.value = priority;
+      }
+    }
+  });
+  document.getElementById("select-all").addEventListener("change", function (e) {
+    rows.querySelectorAll("input[name=id]").forEach(function (box) { box.checked = e.target.checked; });
+  });
+
+  function shown(j) {
+    return !j.deleted &&
+      (!live.queue || j.queue === live.queue) &&
+      (live.states.length === 0 || live.states.indexOf(j.state) >= 0);
+  }
+
+  // Rows are newest first, like the listing: by creation time, then ID.
+  function before(j, row) {
+    var created = Date.parse(j.created_at), other = Number(row.dataset.created);
+    return created > other || (created === other && j.id > row.dataset.id);
+  }
+
+  function applyJob(j) {
+    var row = Array.prototype.find.call(rows.rows, function (r) { return r.dataset.id === j.id; });
+    if (!shown(j)) {
+      if (row) {
+        row.remove();
+      }
+      return;
+    }
+    if (!row) {
+      var next = Array.prototype.find.call(rows.rows, function (r) { return before(j, r); });
+      // A job newer than this page belongs to an earlier one, and one
+      // older than a full page to a later one.
+      if ((next && next === rows.rows[0] && !live.firstPage) || (!next && rows.rows.length >= live.pageSize)) {
+        return;
+      }
+      row = document.createElement("tr");
+      rows.insertBefore(row, next || null);
+      while (rows.rows.length > live.pageSize) {
+        rows.deleteRow(-1);
+      }
+    }
+    render(row, j);
+    row.classList.add("changed");
+    setTimeout(function () { row.classList.remove("changed"); }, 1500);
+  }
+
+  function applyStats(s) {
+    document.querySelectorAll("[data-stat]").forEach(function (el) {
+      el.textContent = s[el.dataset.stat];
+    });
+    var table = document.getElementById("queues");
+    var body = table.tBodies[0];
+    var queues = s.queues || [];
+    body.replaceChildren();
+    queues.forEach(function (q) {
+      var row = body.insertRow();
+      var link = document.createElement("a");
+      link.href = "/?queue=" + encodeURIComponent(q.queue);
+      link.textContent = q.queue;
+      cell(row, link);
+      ["pending", "processing", "completed", "failed", "dead", "blocked", "cancelled", "total"].forEach(function (key) {
+        cell(row, q[key]);
+      });
+    });
+    table.hidden = queues.length === 0;
+  }
+
+  function connect() {
+    var params = new URLSearchParams({since: lastEventId});
+    if (live.queue) {
+      params.set("queue", live.queue);
+    }
+    source = new EventSource("/events?" + params);
+    status.textContent = "connecting…";
+    source.onopen = function () { status.textContent = "● live"; };
+    source.onerror = function () { status.textContent = "reconnecting…"; };
+    source.addEventListener("job", function (e) {
+      lastEventId = e.lastEventId || lastEventId;
+      applyJob(JSON.parse(e.data));
+    });
+    source.addEventListener("stats", function (e) {
+      applyStats(JSON.parse(e.data));
+    });
+  }
+
+  pause.addEventListener("click", function () {
+    if (source) {
+      source.close();
+      source = null;
+      pause.textContent = "Resume";
+      status.textContent = "paused";
+    } else {
+      pause.textContent = "Pause";
+      connect();
+    }
+  });
+  connect();
+})();
+</script>
+</body>
+</html>
+`
+
+const jobTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>Job {{.Job.ID}} - QueueCTL</title>
+{{template "style"}}
+</head>
+<body>
+<p><a href="/">&larr; Dashboard</a></p>
+<h1>Job {{.Job.ID}}</h1>
+{{with .Notice}}<p class="notice">{{.}}</p>{{end}}
+
+<div class="stats">
+  <div><b>State:</b> <span class="state-{{.Job.State}}">{{.Job.State}}</span></div>
+  <div><b>Queue:</b> {{.Job.Queue}}</div>
+  <div><b>Attempts:</b> {{.Job.Attempts}} / {{.Job.MaxRetries}}</div>
+  <div><b>Exit:</b> {{if .Job.ExitCode}}{{.Job.ExitCode}}{{else}}-{{end}}</div>
+  <div><b>Created:</b> {{.Job.CreatedAt.Format "2006-01-02 15:04:05"}}</div>
+</div>
+<pre>{{.Job.CommandLine}}</pre>
+<p><b>Output:</b> <a href="/jobs/{{.Job.ID}}/logs?timestamps=1">stdout</a> &middot; <a href="/jobs/{{.Job.ID}}/logs?stream=stderr&timestamps=1">stderr</a>{{if not .Job.State.Finished}} (live){{end}}</p>
+{{if .Job.LastError}}<p><b>Last error:</b></p><pre>{{.Job.LastError}}</pre>{{end}}
+<form method="post" action="/actions/cancel">
+<input type="hidden" name="csrf_token" value="{{.CSRF}}">
+{{$id := .Job.ID}}{{range actions $.Role .Job}}{{if eq .Name "priority"}}<label>priority <input type="number" name="priority" value="{{$.Job.Priority}}" size="4"></label>
+<button name="only" value="{{$id}}" formaction="/actions/priority?return=/jobs/{{$id}}">Set priority</button>
+{{else}}<button name="only" value="{{$id}}" formaction="/actions/{{.Name}}?return={{if eq .Name "delete"}}/{{else}}/jobs/{{$id}}{{end}}" data-confirm="{{.Confirm}}">{{.Label}}</button>
+{{end}}{{end}}</form>
+{{if .Job.DependsOn}}<p><b>Depends on:</b> {{range .Job.DependsOn}}<a href="/jobs/{{.}}">{{.}}</a> {{end}}</p>{{end}}
+
+{{if or .Stdout .Stderr}}<h2>Latest output</h2>
+{{if .Stdout}}<p><b>stdout</b></p><pre>{{.Stdout}}</pre>{{end}}
+{{if .Stderr}}<p><b>stderr</b></p><pre>{{.Stderr}}</pre>{{end}}
+{{end}}
+
+<h2>Attempts</h2>
+{{range .Attempts}}
+<div class="attempt">
+  <b>#{{.Number}}</b>
+  {{if .FinishedAt}}<span class="state-{{.State}}">{{if .State}}{{.State}}{{else}}discarded{{end}}</span>{{else}}<span class="state-processing">running</span>{{end}}
+  &middot; {{.WorkerID}}
+  &middot; {{.StartedAt.Format "2006-01-02 15:04:05"}}{{if .FinishedAt}} &rarr; {{.FinishedAt.Format "15:04:05"}}{{end}}
+  &middot; {{printf "%.2f" .Duration.Seconds}}s
+  &middot; exit {{if .ExitCode}}{{.ExitCode}}{{else}}-{{end}}
+  {{if .Error}}<p><b>Error:</b> {{.Error}}</p>{{end}}
+  {{if .Stdout}}<p><b>stdout</b> (end; <a href="/jobs/{{.JobID}}/logs?attempt={{.Number}}">full</a>)</p><pre>{{.Stdout}}</pre>{{end}}
+  {{if .Stderr}}<p><b>stderr</b> (end; <a href="/jobs/{{.JobID}}/logs?attempt={{.Number}}&stream=stderr">full</a>)</p><pre>{{.Stderr}}</pre>{{end}}
+</div>
+{{else}}
+<p>This job has not run yet.</p>
+{{end}}
+<script>
+document.querySelectorAll("button[data-confirm]").forEach(function (button) {
+  button.addEventListener("click", function (e) {
+    if (!confirm(button.dataset.confirm.replace("%s", "job " + button.value))) {
+      e.preventDefault();
+    }
+  });
+});
+</script>
 </body>
 </html>
 `
 
 func init() {
+	webCmd.Flags().StringVar(&webAddr, "addr", "127.0.0.1:8080", "address to listen on (e.g., :8080 for all interfaces, which needs web-auth or --insecure)")
+	webCmd.Flags().BoolVar(&webInsecure, "insecure", false, "allow serving a non-loopback --addr while web-auth is none")
+	webCmd.Flags().StringVar(&webTLSCert, "tls-cert", "", "serve HTTPS with this PEM certificate file (needs --tls-key)")
+	webCmd.Flags().StringVar(&webTLSKey, "tls-key", "", "PEM private key file of --tls-cert")
 	rootCmd.AddCommand(webCmd)
 }
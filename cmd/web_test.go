@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoopbackAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:8080": true,
+		"localhost:8080": true,
+		"[::1]:8080":     true,
+		":8080":          false,
+		"0.0.0.0:8080":   false,
+		"[::]:8080":      false,
+		"10.0.0.5:8080":  false,
+		"example.com:80": false,
+	} {
+		if got := loopbackAddr(addr); got != want {
+			t.Errorf("loopbackAddr(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestDashboardReportsStoreErrors(t *testing.T) {
+	useMemoryStore(t)
+	h := serveDashboard(dashboardTemplates())
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	if rec := get("/"); rec.Code != http.StatusOK {
+		t.Fatalf("dashboard: %d %s", rec.Code, rec.Body)
+	}
+	if rec := get("/?cursor=garbage"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad cursor: %d %s", rec.Code, rec.Body)
+	}
+	repo.Close()
+	if rec := get("/"); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("closed store: %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"queuectl.backend/internal/config"
+)
+
+// authReload is how long the web server keeps credentials before reading
+// them again, so added and removed users take effect without a restart.
+const authReload = 5 * time.Second
+
+// roleKey is the context key of the role a request was authenticated with.
+type roleKey struct{}
+
+// webAuth authenticates requests against the users and tokens in the config
+// table and checks the role each request needs.
+type webAuth struct {
+	cfg *config.Repository
+
+	mu       sync.Mutex
+	settings *config.WebAuth
+	loadedAt time.Time
+	// passwords caches good passwords by a hash of the user's stored hash
+	// and the password, so bcrypt runs once per login rather than once per
+	// request. Changing the password changes the stored hash.
+	passwords map[[sha256.Size]byte]bool
+}
+
+func newWebAuth() *webAuth {
+	return &webAuth{cfg: config.NewRepository(repo.DB())}
+}
+
+// load returns the current settings, reading them again when they are
+// older than authReload.
+func (a *webAuth) load() (*config.WebAuth, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.settings != nil && time.Since(a.loadedAt) < authReload {
+		return a.settings, nil
+	}
+	settings, err := a.cfg.WebAuth()
+	if err != nil {
+		return nil, err
+	}
+	a.settings, a.loadedAt = settings, time.Now()
+	if len(a.passwords) > 1000 {
+		a.passwords = nil
+	}
+	return settings, nil
+}
+
+// checkPassword reports whether password is the password of u.
+func (a *webAuth) checkPassword(u *config.Credential, password string) bool {
+	key := sha256.Sum256([]byte(u.Hash + "\x00" + password))
+	a.mu.Lock()
+	ok := a.passwords[key]
+	a.mu.Unlock()
+	if ok {
+		return true
+	}
+	if !u.CheckPassword(password) {
+		return false
+	}
+	a.mu.Lock()
+	if a.passwords == nil {
+		a.passwords = map[[sha256.Size]byte]bool{}
+	}
+	a.passwords[key] = true
+	a.mu.Unlock()
+	return true
+}
+
+// authenticate returns the role of the credentials r carries, or 0 if it
+// carries none that settings accept.
+func (a *webAuth) authenticate(settings *config.WebAuth, r *http.Request) config.Role {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && settings.Modes.Token {
+		if t := settings.Token(strings.TrimSpace(token)); t != nil {
+			return t.Role
+		}
+		return 0
+	}
+	if name, password, ok := r.BasicAuth(); ok && settings.Modes.Basic {
+		if u := settings.User(name); u != nil && a.checkPassword(u, password) {
+			return u.Role
+		}
+	}
+	return 0
+}
+
+// Wrap serves next to requests whose credentials have the role the request
+// needs (see requiredRole). With no auth mode configured every request is
+// served as an admin.
+func (a *webAuth) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settings, err := a.load()
+		if err != nil {
+			log.Printf("web: reading credentials: %v", err)
+			authError(w, r, http.StatusServiceUnavailable, "credentials unavailable")
+			return
+		}
+		role := config.RoleAdmin
+		if settings.Modes.Enabled() {
+			if role = a.authenticate(settings, r); role == 0 {
+				if settings.Modes.Basic {
+					w.Header().Set("WWW-Authenticate", `Basic realm="queuectl", charset="UTF-8"`)
+				} else {
+					w.Header().Set("WWW-Authenticate", `Bearer realm="queuectl"`)
+				}
+				authError(w, r, http.StatusUnauthorized, "authentication required")
+				return
+			}
+			if need := requiredRole(r); role < need {
+				authError(w, r, http.StatusForbidden, "this needs the "+need.String()+" role")
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), roleKey{}, role)))
+	})
+}
+
+// requiredRole is the role a request needs: reading takes a viewer,
+// changing jobs an operator and deleting them an admin. Dashboard actions
+// are posts; serveAction checks the role of each.
+func requiredRole(r *http.Request) config.Role {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return config.RoleViewer
+	case http.MethodDelete:
+		return config.RoleAdmin
+	}
+	return config.RoleOperator
+}
+
+// requestRole returns the role r was authenticated with.
+func requestRole(r *http.Request) config.Role {
+	if role, ok := r.Context().Value(roleKey{}).(config.Role); ok {
+		return role
+	}
+	return config.RoleAdmin
+}
+
+// authError answers a rejected request, in JSON for the API.
+func authError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeJSON(w, status, apiError{Error: msg})
+		return
+	}
+	http.Error(w, msg, status)
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"queuectl.backend/internal/config"
+	"queuectl.backend/internal/job"
+)
+
+// authedServer serves the API and dashboard actions behind web auth as
+// "queuectl web" does, with fresh credentials read from the config table.
+func authedServer() http.Handler {
+	registerAPIOnce.Do(registerAPI)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /actions/{action}", serveAction)
+	mux.Handle("/api/", http.DefaultServeMux)
+	return newWebAuth().Wrap(http.NewCrossOriginProtection().Handler(mux))
+}
+
+type credentials func(*http.Request)
+
+func basicAuth(user string) credentials {
+	return func(r *http.Request) { r.SetBasicAuth(user, user+"-password") }
+}
+
+func bearer(token string) credentials {
+	return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+}
+
+func serveAuthed(h http.Handler, creds credentials, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if strings.HasPrefix(path, "/actions/") {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "token"})
+	}
+	if creds != nil {
+		creds(req)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// setUpAuth stores web-auth modes and a user and token per role, named
+// after the role; users' passwords are the name plus "-password".
+func setUpAuth(t *testing.T, modes string) map[config.Role]string {
+	t.Helper()
+	cfg := config.NewRepository(repo.DB())
+	if err := cfg.Set(config.KeyWebAuth, modes); err != nil {
+		t.Fatal(err)
+	}
+	tokens := map[config.Role]string{}
+	for _, role := range []config.Role{config.RoleViewer, config.RoleOperator, config.RoleAdmin} {
+		if err := cfg.SetUser(role.String(), role.String()+"-password", role); err != nil {
+			t.Fatal(err)
+		}
+		token, err := cfg.CreateToken(role.String(), role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[role] = token
+	}
+	return tokens
+}
+
+func TestWebAuthRoles(t *testing.T) {
+	useMemoryStore(t)
+	setUpAuth(t, "basic,token")
+	h := authedServer()
+	deleteForm := url.Values{"only": {"a"}, csrfField: {"token"}}.Encode()
+
+	for _, creds := range []credentials{nil, basicAuth("nobody"), bearer("qctl_wrong"),
+		func(r *http.Request) { r.SetBasicAuth("admin", "wrong") }} {
+		rec := serveAuthed(h, creds, "GET", "/api/v1/stats", "")
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("bad credentials: %d %s", rec.Code, rec.Body)
+		}
+		if got := rec.Header().Get("WWW-Authenticate"); got != `Basic realm="queuectl", charset="UTF-8"` {
+			t.Fatalf("WWW-Authenticate = %q", got)
+		}
+		if body := decodeBody[apiError](t, rec); body.Error != "authentication required" {
+			t.Fatalf("401 body = %+v", body)
+		}
+	}
+
+	if rec := serveAuthed(h, basicAuth("viewer"), "GET", "/api/v1/stats", ""); rec.Code != http.StatusOK {
+		t.Fatalf("viewer GET: %d %s", rec.Code, rec.Body)
+	}
+	rec := serveAuthed(h, basicAuth("viewer"), "POST", "/api/v1/jobs", `{"id":"v","command":"true"}`)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("viewer POST: %d %s", rec.Code, rec.Body)
+	}
+	if body := decodeBody[apiError](t, rec); body.Error != "this needs the operator role" {
+		t.Fatalf("403 body = %+v", body)
+	}
+
+	if rec := serveAuthed(h, basicAuth("operator"), "POST", "/api/v1/jobs", `{"id":"a","command":"true"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("operator POST: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serveAuthed(h, basicAuth("operator"), "DELETE", "/api/v1/jobs/a", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("operator DELETE: %d %s", rec.Code, rec.Body)
+	}
+	rec = serveAuthed(h, basicAuth("operator"), "POST", "/actions/delete", deleteForm)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "admin role") {
+		t.Fatalf("operator delete action: %d %s", rec.Code, rec.Body)
+	}
+	if _, err := repo.Get("a"); err != nil {
+		t.Fatalf("operator deleted the job: %v", err)
+	}
+
+	if rec := serveAuthed(h, basicAuth("admin"), "POST", "/actions/delete", deleteForm); rec.Code != http.StatusSeeOther {
+		t.Fatalf("admin delete action: %d %s", rec.Code, rec.Body)
+	}
+	if err := repo.Create(&job.Job{ID: "b", Command: "true"}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := serveAuthed(h, basicAuth("admin"), "DELETE", "/api/v1/jobs/b", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("admin DELETE: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestWebAuthTokenOnly(t *testing.T) {
+	useMemoryStore(t)
+	tokens := setUpAuth(t, "token")
+	h := authedServer()
+
+	rec := serveAuthed(h, basicAuth("admin"), "GET", "/api/v1/stats", "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("basic auth in token mode: %d %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("WWW-Authenticate"); got != `Bearer realm="queuectl"` {
+		t.Fatalf("WWW-Authenticate = %q", got)
+	}
+
+	if rec := serveAuthed(h, bearer(tokens[config.RoleViewer]), "GET", "/api/v1/stats", ""); rec.Code != http.StatusOK {
+		t.Fatalf("viewer token GET: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serveAuthed(h, bearer(tokens[config.RoleViewer]), "POST", "/api/v1/jobs", `{"command":"true"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("viewer token POST: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serveAuthed(h, bearer(tokens[config.RoleOperator]), "POST", "/api/v1/jobs", `{"id":"a","command":"true"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("operator token POST: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serveAuthed(h, bearer(tokens[config.RoleAdmin]), "DELETE", "/api/v1/jobs/a", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("admin token DELETE: %d %s", rec.Code, rec.Body)
+	}
+}
@@ -2,15 +2,24 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+	"queuectl.backend/internal/config"
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/metrics"
 	"queuectl.backend/internal/queue"
+	"queuectl.backend/internal/schedule"
 	"queuectl.backend/internal/store"
 )
 
@@ -18,8 +27,20 @@ var (
 	workerCount int
 	timeoutFlag time.Duration
 	backoffBase time.Duration
+	leaseFlag   time.Duration
+	ephemeral   bool
+	queuesFlag  []string
+	policyFlag  string
+	weightsFlag map[string]int
+	agingStep   time.Duration
+	withSched   bool
+	metricsAddr string
 )
 
+// workerInsecure is --insecure: serve --metrics-addr off loopback without
+// web-auth.
+var workerInsecure bool
+
 var workerCmd = &cobra.Command{
 	Use:   "worker start",
 	Short: "Start one or more background workers to process queued jobs",
@@ -28,8 +49,17 @@ Each worker continuously polls for new jobs and executes them.
 
 Examples:
   queuectl worker start --count 3 --timeout 30s
-  queuectl worker start --count 2 --backoff-base 2s`,
+  queuectl worker start --count 2 --backoff-base 2s
+  queuectl worker start --lease 1m
+  queuectl worker start --ephemeral
+  queuectl worker start --queues emails,reports
+  queuectl worker start --policy weighted --queue-weights emails=3,reports=1
+  queuectl worker start --policy aging --aging-step 30s
+  queuectl worker start --count 4 --metrics-addr 127.0.0.1:9100`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if ephemeral {
+			dbURL = store.MemoryDSN
+		}
 		CommonInit()
 
 		if workerCount <= 0 {
@@ -38,32 +68,74 @@ Examples:
 		if backoffBase <= 0 {
 			backoffBase = 5 * time.Second
 		}
-
-		db, err := store.InitDB()
+		policy, err := queue.ParsePolicy(policyFlag)
 		if err != nil {
-			log.Fatalf("DB init failed: %v", err)
+			log.Fatal(err)
+		}
+		// Without queues to weigh, weighted would quietly act like strict.
+		if policy == queue.PolicyWeighted && len(queuesFlag) == 0 && len(weightsFlag) == 0 {
+			log.Fatalf("--policy weighted needs --queues or --queue-weights")
 		}
-		repo := store.NewJobRepo(db)
 
 		// Create cancelable context for graceful shutdown
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 		defer stop()
 
-		log.Printf("🚀 Starting %d worker(s) | timeout=%v | backoff-base=%v", workerCount, timeoutFlag, backoffBase)
+		exitCodes, err := exitCodeRules()
+		if err != nil {
+			log.Fatal(err)
+		}
+		logSize, err := maxLogSize()
+		if err != nil {
+			log.Fatal(err)
+		}
+		var workerMetrics *queue.Metrics
+		if metricsAddr != "" {
+			if workerMetrics, err = serveWorkerMetrics(metricsAddr, workerInsecure); err != nil {
+				log.Fatalf("Failed to serve metrics: %v", err)
+			}
+		}
+
+		queues := "all"
+		if len(queuesFlag) > 0 {
+			queues = strings.Join(queuesFlag, ",")
+		}
+		log.Printf("🚀 Starting %d worker(s) | queues=%s | policy=%s | timeout=%v | backoff-base=%v | lease=%v", workerCount, queues, policy, timeoutFlag, backoffBase, leaseFlag)
+
+		// Worker IDs own job leases, so they must be unique across processes and hosts.
+		host, err := os.Hostname()
+		if err != nil {
+			host = "localhost"
+		}
 
 		var wg sync.WaitGroup
+		if withSched {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				schedule.Run(ctx, schedule.NewRepository(repo), 5*time.Second)
+			}()
+		}
 		for i := 1; i <= workerCount; i++ {
 			wg.Add(1)
-			id := fmt.Sprintf("worker-%d", i)
+			id := fmt.Sprintf("%s-%d-worker-%d", host, os.Getpid(), i)
 
 			go func(workerID string) {
 				defer wg.Done()
 				worker := queue.NewWorker(repo, queue.WorkerConfig{
-					ID:           workerID,
-					PollInterval: 2 * time.Second,
-					MaxSleepTime: 30 * time.Second,
-					RetryDelay:   backoffBase, // ✅ configurable base delay
-					ExecTimeout:  timeoutFlag,
+					ID:            workerID,
+					PollInterval:  2 * time.Second,
+					MaxSleepTime:  30 * time.Second,
+					RetryDelay:    backoffBase, // ✅ configurable base delay
+					ExecTimeout:   timeoutFlag,
+					LeaseDuration: leaseFlag,
+					Queues:        queuesFlag,
+					Policy:        policy,
+					QueueWeights:  weightsFlag,
+					AgingStep:     agingStep,
+					ExitCodes:     exitCodes,
+					MaxLogSize:    logSize,
+					Metrics:       workerMetrics,
 				})
 				if err := worker.Run(ctx); err != nil {
 					log.Printf("[%s] exited with error: %v", workerID, err)
@@ -79,7 +151,91 @@ Examples:
 
 func init() {
 	workerCmd.Flags().IntVarP(&workerCount, "count", "c", 1, "number of workers to start")
-	workerCmd.Flags().DurationVar(&timeoutFlag, "timeout", time.Minute, "maximum execution time per job (e.g., 30s, 2m)")
-	workerCmd.Flags().DurationVar(&backoffBase, "backoff-base", 5*time.Second, "base retry backoff duration (e.g., 2s, 5s, 10s)")
+	workerCmd.Flags().DurationVar(&timeoutFlag, "timeout", time.Minute, "maximum execution time for jobs that set no timeout (e.g., 30s, 2m)")
+	workerCmd.Flags().DurationVar(&backoffBase, "backoff-base", 5*time.Second, "base retry backoff for jobs whose backoff sets no base (e.g., 2s, 5s, 10s)")
+	workerCmd.Flags().DurationVar(&leaseFlag, "lease", 30*time.Second, "how long a claimed job stays reserved without a heartbeat before it is reclaimed")
+	workerCmd.Flags().BoolVar(&ephemeral, "ephemeral", false, "use a throwaway in-memory database instead of --db")
+	workerCmd.Flags().StringSliceVarP(&queuesFlag, "queues", "q", nil, "comma-separated queues to take jobs from (default: all queues)")
+	workerCmd.Flags().StringVar(&policyFlag, "policy", string(queue.PolicyStrict), "scheduling policy: strict, weighted (round-robin across queues) or aging")
+	workerCmd.Flags().StringToIntVar(&weightsFlag, "queue-weights", nil, "queue weights for --policy weighted (e.g., emails=3,reports=1)")
+	workerCmd.Flags().DurationVar(&agingStep, "aging-step", time.Minute, "waiting time that adds 1 to a job's priority under --policy aging")
+	workerCmd.Flags().BoolVar(&withSched, "scheduler", true, "also run the scheduler that enqueues recurring jobs")
+	workerCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "serve this process's worker metrics at http://<addr>/metrics (e.g., 127.0.0.1:9100; other hosts need web-auth or --insecure)")
+	workerCmd.Flags().BoolVar(&workerInsecure, "insecure", false, "allow a non-loopback --metrics-addr while web-auth is none")
 	rootCmd.AddCommand(workerCmd)
 }
+
+// exitCodeRules reads the global exit code retry rules from the config table.
+func exitCodeRules() (job.ExitCodeRules, error) {
+	var rules job.ExitCodeRules
+	items, err := config.NewRepository(repo.DB()).All()
+	if err != nil {
+		return rules, fmt.Errorf("failed to read config: %w", err)
+	}
+	for _, item := range items {
+		var target *[]int
+		switch item.Key {
+		case config.KeyRetryOnExitCodes:
+			target = &rules.RetryOn
+		case config.KeyFailFastExitCodes:
+			target = &rules.FailFast
+		default:
+			continue
+		}
+		codes, err := job.ParseExitCodes(item.Value)
+		if err != nil {
+			return rules, fmt.Errorf("invalid config %s: %w", item.Key, err)
+		}
+		*target = codes
+	}
+	return rules, nil
+}
+
+// maxLogSize reads the per-stream output cap from the config table; 0 means
+// the worker default.
+func maxLogSize() (int64, error) {
+	value, err := config.NewRepository(repo.DB()).Get(config.KeyMaxLogSize)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to read config: %w", err)
+	}
+	size, err := config.ParseSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid config %s: %w", config.KeyMaxLogSize, err)
+	}
+	return max(size, config.MinLogSize), nil
+}
+
+// serveWorkerMetrics serves the metrics of this process's workers on addr in
+// the background. Queue-wide totals are left to "queuectl web", so scraping
+// several worker processes does not count them twice. Like "queuectl web",
+// it refuses a non-loopback addr while web-auth is none unless insecure.
+func serveWorkerMetrics(addr string, insecure bool) (*queue.Metrics, error) {
+	auth := newWebAuth()
+	settings, err := auth.load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read web credentials: %w", err)
+	}
+	if !settings.Modes.Enabled() {
+		if !loopbackAddr(addr) && !insecure {
+			return nil, fmt.Errorf("refusing to serve %s without authentication: set web-auth (see \"queuectl auth\") or pass --insecure", addr)
+		}
+		log.Printf("⚠️ Authentication is off: everyone who can reach %s may read the worker metrics", addr)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	reg := metrics.NewRegistry()
+	m := queue.NewMetrics(reg)
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", reg)
+	// Scrapers authenticate as on "queuectl web", with a viewer token.
+	handler := auth.Wrap(mux)
+	go func() {
+		log.Printf("worker metrics server stopped: %v", http.Serve(ln, handler))
+	}()
+	log.Printf("📈 Serving worker metrics at http://%s/metrics", ln.Addr())
+	return m, nil
+}
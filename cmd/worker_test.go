@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWorkerMetricsNeedAuthOffLoopback(t *testing.T) {
+	useMemoryStore(t)
+	if _, err := serveWorkerMetrics("0.0.0.0:0", false); err == nil || !strings.Contains(err.Error(), "refusing") {
+		t.Fatalf("served all interfaces without auth: %v", err)
+	}
+	if _, err := serveWorkerMetrics("127.0.0.1:0", false); err != nil {
+		t.Fatalf("loopback: %v", err)
+	}
+	if _, err := serveWorkerMetrics("0.0.0.0:0", true); err != nil {
+		t.Fatalf("--insecure: %v", err)
+	}
+
+	setUpAuth(t, "token")
+	if _, err := serveWorkerMetrics("0.0.0.0:0", false); err != nil {
+		t.Fatalf("with web-auth: %v", err)
+	}
+}
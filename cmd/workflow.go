@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"queuectl.backend/internal/job"
+)
+
+var keepIDs bool
+
+// workflowFile is the YAML (or JSON) layout accepted by "workflow submit".
+// Jobs use the same fields as the enqueue JSON.
+type workflowFile struct {
+	Name         string     `json:"name"`
+	Queue        string     `json:"queue"`
+	OnParentDead string     `json:"on_parent_dead"`
+	Jobs         []*job.Job `json:"jobs"`
+}
+
+var workflowCmd = &cobra.Command{
+	Use:   "workflow",
+	Short: "Submit DAGs of jobs that depend on each other",
+}
+
+var workflowSubmitCmd = &cobra.Command{
+	Use:   "submit <file.yaml>",
+	Short: "Enqueue every job of a workflow file atomically",
+	Long: `Enqueue a whole DAG in one transaction. Jobs wait in the "blocked" state
+until everything in their depends_on list has completed.
+
+Job IDs in the file are local names: each submission prefixes them with
+"<name>-<timestamp>-" (and rewrites depends_on accordingly) so a file can be
+submitted many times. Use --keep-ids to store them verbatim. depends_on entries
+that are not defined in the file refer to jobs already in the queue.
+
+Example file:
+  name: etl
+  queue: batch
+  on_parent_dead: cascade   # or skip
+  jobs:
+    - id: extract
+      command: ./extract.sh
+    - id: transform
+      command: ./transform.sh
+      depends_on: [extract]
+    - id: load
+      command: ./load.sh
+      depends_on: [transform]`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		CommonInit()
+
+		wf, err := readWorkflow(args[0])
+		if err != nil {
+			log.Fatalf("Invalid workflow file: %v", err)
+		}
+		if len(wf.Jobs) == 0 {
+			log.Fatalf("Invalid workflow file: no jobs defined")
+		}
+
+		prefix := ""
+		if !keepIDs {
+			name := wf.Name
+			if name == "" {
+				name = "wf"
+			}
+			prefix = fmt.Sprintf("%s-%d-", name, time.Now().UnixNano())
+		}
+
+		local := make(map[string]bool, len(wf.Jobs))
+		for _, j := range wf.Jobs {
+			local[j.ID] = true
+		}
+		for i, j := range wf.Jobs {
+			if j.ID == "" {
+				log.Fatalf("Invalid workflow file: job #%d has no id", i+1)
+			}
+			if err := j.ValidatePayload(); err != nil {
+				log.Fatalf("Invalid workflow file: job %s: %v", j.ID, err)
+			}
+			j.ID = prefix + j.ID
+			for k, parent := range j.DependsOn {
+				if local[parent] {
+					j.DependsOn[k] = prefix + parent
+				}
+			}
+			if j.Queue == "" {
+				j.Queue = wf.Queue
+			}
+			if j.OnParentDead == "" {
+				j.OnParentDead = wf.OnParentDead
+			}
+			if j.MaxRetries == 0 {
+				j.MaxRetries = job.DefaultMaxRetries
+			}
+			j.State = job.StatePending
+		}
+
+		if err := repo.CreateWorkflow(wf.Jobs); err != nil {
+			log.Fatalf("Failed to submit workflow: %v", err)
+		}
+
+		fmt.Printf("Workflow submitted with %d job(s):\n", len(wf.Jobs))
+		for _, j := range wf.Jobs {
+			fmt.Printf("- %s [%s]", j.ID, j.State)
+			if len(j.DependsOn) > 0 {
+				fmt.Printf(" depends on %v", j.DependsOn)
+			}
+			fmt.Println()
+		}
+	},
+}
+
+// readWorkflow parses a workflow file. YAML is converted to JSON first so the
+// job fields keep the same names as in the enqueue JSON.
+func readWorkflow(path string) (*workflowFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	asJSON, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var wf workflowFile
+	if err := json.Unmarshal(asJSON, &wf); err != nil {
+		return nil, err
+	}
+	return &wf, nil
+}
+
+func init() {
+	workflowSubmitCmd.Flags().BoolVar(&keepIDs, "keep-ids", false, "use the job IDs from the file verbatim instead of prefixing them")
+	workflowCmd.AddCommand(workflowSubmitCmd)
+	rootCmd.AddCommand(workflowCmd)
+}
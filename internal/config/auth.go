@@ -0,0 +1,249 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Keys read by the web server. KeyWebAuth lists the credentials it accepts
+// (see ParseAuthModes); users and tokens are stored under the prefixes
+// followed by their name, with a role and a hash as the value.
+const (
+	KeyWebAuth     = "web-auth"
+	UserKeyPrefix  = "web-user:"
+	TokenKeyPrefix = "web-token:"
+)
+
+// tokenPrefix starts every generated API token, so leaked tokens are easy
+// to spot in logs and by secret scanners.
+const tokenPrefix = "qctl_"
+
+// ErrCredentialNotFound is returned when removing a user or token that does
+// not exist.
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// Role is what a user or token may do on the web server. Each role may do
+// everything the roles before it may.
+type Role int
+
+const (
+	// RoleViewer reads the dashboard, the API, events and metrics.
+	RoleViewer Role = iota + 1
+	// RoleOperator also enqueues, cancels, retries and reprioritizes jobs.
+	RoleOperator
+	// RoleAdmin also deletes jobs.
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{RoleViewer: "viewer", RoleOperator: "operator", RoleAdmin: "admin"}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("role(%d)", int(r))
+}
+
+// MarshalText makes roles read as their names in JSON and YAML output.
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// ParseRole reads "viewer", "operator" or "admin".
+func ParseRole(s string) (Role, error) {
+	for role, name := range roleNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return role, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid role %q (want viewer, operator or admin)", s)
+}
+
+// AuthModes are the kinds of credentials the web server accepts. With
+// neither, it serves everyone as an admin.
+type AuthModes struct {
+	Basic bool // HTTP basic auth with a user name and password
+	Token bool // "Authorization: Bearer <token>"
+}
+
+func (m AuthModes) Enabled() bool { return m.Basic || m.Token }
+
+func (m AuthModes) String() string {
+	var modes []string
+	if m.Basic {
+		modes = append(modes, "basic")
+	}
+	if m.Token {
+		modes = append(modes, "token")
+	}
+	if len(modes) == 0 {
+		return "none"
+	}
+	return strings.Join(modes, ",")
+}
+
+func (m AuthModes) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// ParseAuthModes reads a web-auth value: "none", "basic", "token" or both
+// separated by a comma.
+func ParseAuthModes(s string) (AuthModes, error) {
+	var m AuthModes
+	for _, mode := range strings.Split(s, ",") {
+		switch strings.ToLower(strings.TrimSpace(mode)) {
+		case "", "none":
+		case "basic":
+			m.Basic = true
+		case "token":
+			m.Token = true
+		default:
+			return AuthModes{}, fmt.Errorf("invalid auth mode %q (want none, basic, token or basic,token)", mode)
+		}
+	}
+	return m, nil
+}
+
+// Credential is a web user or API token. Only a hash of its secret is
+// stored: bcrypt for passwords, SHA-256 for tokens, which are random and
+// long enough not to need a slow hash.
+type Credential struct {
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+	Hash string `json:"-"`
+}
+
+// CheckPassword reports whether password is the user's. It is slow on
+// purpose; callers checking every request should cache the result.
+func (c *Credential) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(c.Hash), []byte(password)) == nil
+}
+
+// CheckToken reports whether token is this API token.
+func (c *Credential) CheckToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(c.Hash)) == 1
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+var credentialName = regexp.MustCompile(`^[A-Za-z0-9._@-]{1,64}$`)
+
+// SetUser adds a web user, or changes the password and role of an
+// existing one.
+func (r *Repository) SetUser(name, password string, role Role) error {
+	if !credentialName.MatchString(name) {
+		return fmt.Errorf("invalid user name %q (use letters, digits and . _ @ -)", name)
+	}
+	if password == "" {
+		return errors.New("password must not be empty")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return r.Set(UserKeyPrefix+name, role.String()+" "+string(hash))
+}
+
+// CreateToken stores a new API token, replacing any token of the same
+// name, and returns it. The token cannot be recovered later.
+func (r *Repository) CreateToken(name string, role Role) (string, error) {
+	if !credentialName.MatchString(name) {
+		return "", fmt.Errorf("invalid token name %q (use letters, digits and . _ @ -)", name)
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := tokenPrefix + hex.EncodeToString(b)
+	return token, r.Set(TokenKeyPrefix+name, role.String()+" "+hashToken(token))
+}
+
+// RemoveUser deletes a web user.
+func (r *Repository) RemoveUser(name string) error {
+	return r.remove(UserKeyPrefix + name)
+}
+
+// RemoveToken deletes an API token.
+func (r *Repository) RemoveToken(name string) error {
+	return r.remove(TokenKeyPrefix + name)
+}
+
+func (r *Repository) remove(key string) error {
+	res := r.db.Delete(&Config{}, "key = ?", key)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrCredentialNotFound
+	}
+	return nil
+}
+
+// WebAuth is the web server's authentication settings.
+type WebAuth struct {
+	Modes  AuthModes    `json:"modes"`
+	Users  []Credential `json:"users"`
+	Tokens []Credential `json:"tokens"`
+}
+
+// User returns the web user called name, or nil.
+func (a *WebAuth) User(name string) *Credential {
+	for i := range a.Users {
+		if a.Users[i].Name == name {
+			return &a.Users[i]
+		}
+	}
+	return nil
+}
+
+// Token returns the API token token, or nil.
+func (a *WebAuth) Token(token string) *Credential {
+	for i := range a.Tokens {
+		if a.Tokens[i].CheckToken(token) {
+			return &a.Tokens[i]
+		}
+	}
+	return nil
+}
+
+// WebAuth reads the web-auth modes and the users and tokens, ordered by
+// name. Unset modes mean none.
+func (r *Repository) WebAuth() (*WebAuth, error) {
+	var items []Config
+	err := r.db.Where("key = ? OR key LIKE ? OR key LIKE ?", KeyWebAuth, UserKeyPrefix+"%", TokenKeyPrefix+"%").
+		Order("key").Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	a := &WebAuth{Users: []Credential{}, Tokens: []Credential{}}
+	for _, item := range items {
+		if item.Key == KeyWebAuth {
+			if a.Modes, err = ParseAuthModes(item.Value); err != nil {
+				return nil, fmt.Errorf("%s: %w", item.Key, err)
+			}
+			continue
+		}
+		roleName, hash, _ := strings.Cut(item.Value, " ")
+		role, err := ParseRole(roleName)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", item.Key, err)
+		}
+		if name, ok := strings.CutPrefix(item.Key, UserKeyPrefix); ok {
+			a.Users = append(a.Users, Credential{Name: name, Role: role, Hash: hash})
+		} else {
+			a.Tokens = append(a.Tokens, Credential{Name: strings.TrimPrefix(item.Key, TokenKeyPrefix), Role: role, Hash: hash})
+		}
+	}
+	return a, nil
+}
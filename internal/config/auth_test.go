@@ -0,0 +1,90 @@
+package config_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"queuectl.backend/internal/config"
+	"queuectl.backend/internal/store"
+)
+
+func TestWebAuthCredentials(t *testing.T) {
+	jobs, err := store.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { jobs.Close() })
+	repo := config.NewRepository(jobs.DB())
+
+	settings, err := repo.WebAuth()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Modes.Enabled() {
+		t.Fatalf("auth enabled without config: %s", settings.Modes)
+	}
+
+	if err := repo.Set(config.KeyWebAuth, "basic, token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetUser("alice", "s3cret", config.RoleOperator); err != nil {
+		t.Fatal(err)
+	}
+	token, err := repo.CreateToken("prometheus", config.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetUser("bad name", "x", config.RoleAdmin); err == nil {
+		t.Fatal("accepted a user name with a space")
+	}
+
+	settings, err = repo.WebAuth()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := settings.Modes.String(); got != "basic,token" {
+		t.Fatalf("modes = %s", got)
+	}
+	alice := settings.User("alice")
+	if alice == nil || alice.Role != config.RoleOperator {
+		t.Fatalf("alice = %+v", alice)
+	}
+	if strings.Contains(alice.Hash, "s3cret") || !alice.CheckPassword("s3cret") || alice.CheckPassword("secret") {
+		t.Fatal("password not hashed or not checked")
+	}
+	if tok := settings.Token(token); tok == nil || tok.Name != "prometheus" || tok.Role != config.RoleViewer {
+		t.Fatalf("token lookup = %+v", tok)
+	}
+	if settings.Token(token+"x") != nil || settings.User("bob") != nil {
+		t.Fatal("unknown credentials were accepted")
+	}
+
+	if err := repo.RemoveToken("prometheus"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RemoveToken("prometheus"); !errors.Is(err, config.ErrCredentialNotFound) {
+		t.Fatalf("second remove: %v", err)
+	}
+	if settings, _ = repo.WebAuth(); len(settings.Tokens) != 0 || len(settings.Users) != 1 {
+		t.Fatalf("after remove: %+v", settings)
+	}
+}
+
+func TestParseRoleAndModes(t *testing.T) {
+	if role, err := config.ParseRole("Admin"); err != nil || role != config.RoleAdmin {
+		t.Fatalf("ParseRole(Admin) = %v, %v", role, err)
+	}
+	if _, err := config.ParseRole("root"); err == nil {
+		t.Fatal("accepted role root")
+	}
+	if !(config.RoleViewer < config.RoleOperator && config.RoleOperator < config.RoleAdmin) {
+		t.Fatal("roles out of order")
+	}
+	if m, err := config.ParseAuthModes("none"); err != nil || m.Enabled() {
+		t.Fatalf("ParseAuthModes(none) = %v, %v", m, err)
+	}
+	if _, err := config.ParseAuthModes("basic,oauth"); err == nil {
+		t.Fatal("accepted mode oauth")
+	}
+}
@@ -1,10 +1,50 @@
 package config
 
-import "gorm.io/gorm"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Keys read by workers. The exit code keys hold comma-separated exit codes
+// that act as defaults for jobs without their own retry_on/fail_fast_exit_codes.
+const (
+	KeyRetryOnExitCodes  = "retry-on-exit-codes"
+	KeyFailFastExitCodes = "fail-fast-exit-codes"
+	// KeyMaxLogSize caps the output stored per stream and attempt, as a size
+	// such as "512KB" or "2MB" (see ParseSize).
+	KeyMaxLogSize = "max-log-size"
+)
+
+// MinLogSize is the smallest accepted max-log-size.
+const MinLogSize = 1024
+
+// ParseSize reads a byte count with an optional KB, MB or GB suffix (powers
+// of 1024), such as "4096", "512KB" or "2MB".
+func ParseSize(s string) (int64, error) {
+	num := strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(num, u.suffix) {
+			num, unit = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (want bytes or a number with KB, MB or GB)", s)
+	}
+	return n * unit, nil
+}
 
 type Config struct {
-	Key   string `gorm:"primaryKey"`
-	Value string
+	Key   string `json:"key" gorm:"primaryKey"`
+	Value string `json:"value"`
 }
 
 // Repository wraps access to the config table.
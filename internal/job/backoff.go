@@ -0,0 +1,123 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff strategies for Backoff.Strategy.
+const (
+	BackoffExponential = "exponential"
+	BackoffLinear      = "linear"
+	BackoffFixed       = "fixed"
+)
+
+// DefaultBackoffMax caps retry delays when a job does not set backoff.max.
+const DefaultBackoffMax = 1 * time.Hour
+
+// Duration is a time.Duration written as a Go duration string ("30s", "5m")
+// in job JSON. Plain numbers are read as seconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", v, err)
+		}
+		*d = Duration(parsed)
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("invalid duration %s", b)
+	}
+	return nil
+}
+
+// Backoff decides how long a failed job waits before its next attempt.
+type Backoff struct {
+	// Strategy is exponential (the default), linear or fixed.
+	Strategy string `json:"strategy,omitempty"`
+	// Base is the first delay; zero uses the worker's --backoff-base.
+	Base Duration `json:"base,omitempty"`
+	// Max caps the delay, jitter included; zero means DefaultBackoffMax.
+	Max Duration `json:"max,omitempty"`
+	// Jitter spreads each delay randomly by up to this fraction of itself
+	// (0.2 = ±20%) so retries of many jobs failing together do not line up.
+	Jitter float64 `json:"jitter,omitempty"`
+}
+
+// Validate reports an unknown strategy or out-of-range values.
+func (b *Backoff) Validate() error {
+	if b == nil {
+		return nil
+	}
+	switch b.Strategy {
+	case "", BackoffExponential, BackoffLinear, BackoffFixed:
+	default:
+		return fmt.Errorf("invalid backoff strategy %q (want %s, %s or %s)", b.Strategy, BackoffExponential, BackoffLinear, BackoffFixed)
+	}
+	if b.Base < 0 || b.Max < 0 {
+		return fmt.Errorf("backoff base and max cannot be negative")
+	}
+	if b.Jitter < 0 || b.Jitter > 1 {
+		return fmt.Errorf("backoff jitter must be between 0 and 1, got %v", b.Jitter)
+	}
+	return nil
+}
+
+// Delay returns the wait before retrying after the given failed attempt
+// (1 for the first failure). A nil Backoff is exponential from defaultBase.
+func (b *Backoff) Delay(attempt int32, defaultBase time.Duration) time.Duration {
+	var p Backoff
+	if b != nil {
+		p = *b
+	}
+	base := time.Duration(p.Base)
+	if base <= 0 {
+		base = defaultBase
+	}
+	max := time.Duration(p.Max)
+	if max <= 0 {
+		max = DefaultBackoffMax
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	var delay time.Duration
+	switch p.Strategy {
+	case BackoffFixed:
+		delay = base
+	case BackoffLinear:
+		delay = base * time.Duration(attempt)
+	default:
+		delay = base
+		for i := int32(1); i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+	}
+	if delay > max || delay < 0 {
+		delay = max // also catches overflow, before jitter scales it
+	}
+
+	if p.Jitter > 0 {
+		spread := float64(delay) * p.Jitter
+		delay += time.Duration((rand.Float64()*2 - 1) * spread)
+	}
+	// Clamp after jitter so Max holds for every delay, not just the mean.
+	return min(delay, max)
+}
@@ -0,0 +1,69 @@
+package job
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Exit code conventions from sysexits.h.
+const (
+	// ExitUsage (EX_USAGE) means the job was invoked wrongly; it goes
+	// straight to the DLQ because retrying cannot help.
+	ExitUsage = 64
+	// ExitTempFail (EX_TEMPFAIL) asks for a retry even when the job only
+	// retries an explicit list of codes.
+	ExitTempFail = 75
+)
+
+// ExitCodeRules are the global defaults for Job.RetryOnExitCodes and
+// Job.FailFastExitCodes, set with the retry-on-exit-codes and
+// fail-fast-exit-codes config keys.
+type ExitCodeRules struct {
+	RetryOn  []int
+	FailFast []int
+}
+
+// ShouldRetry reports whether a run that exited with code may be retried
+// (while attempts remain). The job's own lists replace the defaults when set.
+// A negative code means the command never exited on its own (timeout, kill)
+// and is always retried.
+func (j *Job) ShouldRetry(code int, defaults ExitCodeRules) bool {
+	retryOn, failFast := defaults.RetryOn, defaults.FailFast
+	if j.RetryOnExitCodes != nil {
+		retryOn = j.RetryOnExitCodes
+	}
+	if j.FailFastExitCodes != nil {
+		failFast = j.FailFastExitCodes
+	}
+
+	switch {
+	case code < 0:
+		return true
+	case slices.Contains(failFast, code):
+		return false
+	case code == ExitTempFail || slices.Contains(retryOn, code):
+		return true
+	case len(retryOn) > 0:
+		return false
+	}
+	return code != ExitUsage
+}
+
+// ParseExitCodes reads a comma-separated list of exit codes such as "1,75".
+func ParseExitCodes(s string) ([]int, error) {
+	var codes []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		code, err := strconv.Atoi(part)
+		if err != nil || code < 0 || code > 255 {
+			return nil, fmt.Errorf("invalid exit code %q", part)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
@@ -1,6 +1,7 @@
 package job_test
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -9,14 +10,11 @@ import (
 )
 
 func TestCreateAndFetchJob(t *testing.T) {
-	db, err := store.InitDB()
+	repo, err := store.OpenMemory()
 	if err != nil {
 		t.Fatal(err)
 	}
-	repo := store.NewJobRepo(db)
-
-	// Clean up before test
-	db.Exec("DELETE FROM jobs")
+	t.Cleanup(func() { repo.Close() })
 
 	// Create a new job
 	j := &job.Job{
@@ -41,3 +39,122 @@ func TestCreateAndFetchJob(t *testing.T) {
 		t.Fatalf("expected job ID %s, got %+v", j.ID, fetched)
 	}
 }
+
+func TestBackoffDelay(t *testing.T) {
+	var j job.Job
+	err := json.Unmarshal([]byte(`{"command":"true","timeout":"90s","backoff":{"strategy":"linear","base":2,"max":"5s"}}`), &j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(j.Timeout) != 90*time.Second {
+		t.Fatalf("expected 90s timeout, got %v", time.Duration(j.Timeout))
+	}
+
+	cases := []struct {
+		backoff *job.Backoff
+		attempt int32
+		want    time.Duration
+	}{
+		{nil, 1, time.Second},
+		{nil, 4, 8 * time.Second},
+		{nil, 40, job.DefaultBackoffMax},
+		{j.Backoff, 2, 4 * time.Second},
+		{j.Backoff, 3, 5 * time.Second},
+		{&job.Backoff{Strategy: job.BackoffFixed, Base: job.Duration(3 * time.Second)}, 5, 3 * time.Second},
+	}
+	for _, c := range cases {
+		if got := c.backoff.Delay(c.attempt, time.Second); got != c.want {
+			t.Errorf("Delay(%d) with %+v = %v, want %v", c.attempt, c.backoff, got, c.want)
+		}
+	}
+
+	jittered := &job.Backoff{Strategy: job.BackoffFixed, Base: job.Duration(10 * time.Second), Jitter: 0.5}
+	for range 100 {
+		if d := jittered.Delay(1, time.Second); d < 5*time.Second || d > 15*time.Second {
+			t.Fatalf("jittered delay %v outside ±50%% of 10s", d)
+		}
+	}
+
+	// Jitter never pushes a capped delay past Max.
+	capped := &job.Backoff{Base: job.Duration(time.Second), Max: job.Duration(10 * time.Second), Jitter: 0.5}
+	for range 200 {
+		if d := capped.Delay(10, time.Second); d > 10*time.Second || d < 5*time.Second {
+			t.Fatalf("capped jittered delay %v outside 5s-10s", d)
+		}
+	}
+
+	if err := (&job.Backoff{Strategy: "random"}).Validate(); err == nil {
+		t.Fatal("expected an unknown strategy to be rejected")
+	}
+}
+
+func TestShouldRetryByExitCode(t *testing.T) {
+	global := job.ExitCodeRules{FailFast: []int{2}}
+	cases := []struct {
+		job  job.Job
+		code int
+		want bool
+	}{
+		{job.Job{}, 1, true},
+		{job.Job{}, job.ExitUsage, false},
+		{job.Job{}, 2, false},
+		{job.Job{}, -1, true},
+		{job.Job{FailFastExitCodes: []int{}}, 2, true},
+		{job.Job{RetryOnExitCodes: []int{3}}, 3, true},
+		{job.Job{RetryOnExitCodes: []int{3}}, 1, false},
+		{job.Job{RetryOnExitCodes: []int{3}}, job.ExitTempFail, true},
+		{job.Job{FailFastExitCodes: []int{job.ExitTempFail}}, job.ExitTempFail, false},
+	}
+	for _, c := range cases {
+		if got := c.job.ShouldRetry(c.code, global); got != c.want {
+			t.Errorf("ShouldRetry(%d) with retry_on=%v fail_fast=%v = %v, want %v",
+				c.code, c.job.RetryOnExitCodes, c.job.FailFastExitCodes, got, c.want)
+		}
+	}
+}
+
+func TestExecModeAndCommandLine(t *testing.T) {
+	cases := []struct {
+		j       job.Job
+		mode    string
+		line    string
+		invalid bool
+	}{
+		{j: job.Job{Command: "echo hi"}, mode: job.ExecShell, line: "echo hi"},
+		{j: job.Job{Args: []string{"cp", "my file", "it's"}}, mode: job.ExecDirect, line: `cp 'my file' 'it'\''s'`},
+		{j: job.Job{Command: "/bin/ls", Args: []string{"-l"}}, mode: job.ExecDirect, line: "/bin/ls -l"},
+		{j: job.Job{Command: "ls", ExecMode: job.ExecDirect}, mode: job.ExecDirect, line: "ls"},
+		{j: job.Job{Args: []string{"x"}, ExecMode: job.ExecShell}, mode: job.ExecShell, invalid: true},
+		{j: job.Job{Command: "ls", ExecMode: "spawn"}, mode: "spawn", invalid: true},
+		{j: job.Job{Command: "ls", Env: map[string]string{"A=B": "c"}}, mode: job.ExecShell, invalid: true},
+	}
+	for _, c := range cases {
+		if got := c.j.Mode(); got != c.mode {
+			t.Errorf("%+v: mode %q, want %q", c.j, got, c.mode)
+		}
+		if err := c.j.ValidatePayload(); (err != nil) != c.invalid {
+			t.Errorf("%+v: ValidatePayload() = %v", c.j, err)
+		}
+		if !c.invalid && c.j.CommandLine() != c.line {
+			t.Errorf("%+v: CommandLine() = %q, want %q", c.j, c.j.CommandLine(), c.line)
+		}
+	}
+}
+
+func TestNormalizeFillsDefaults(t *testing.T) {
+	j := job.Job{Command: "true"}
+	if err := j.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if j.State != job.StatePending || j.Queue != job.DefaultQueue || j.MaxRetries != job.DefaultMaxRetries || j.CreatedAt.IsZero() || !j.UpdatedAt.Equal(j.CreatedAt) {
+		t.Fatalf("defaults not applied: %+v", j)
+	}
+
+	j = job.Job{Command: "true", Queue: "emails", MaxRetries: 7}
+	if err := j.Normalize(); err != nil || j.Queue != "emails" || j.MaxRetries != 7 {
+		t.Fatalf("explicit values overwritten: %+v, %v", j, err)
+	}
+	if err := (&job.Job{}).Normalize(); err == nil {
+		t.Fatal("accepted a job without a command")
+	}
+}
@@ -0,0 +1,124 @@
+package job
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Output streams of a LogChunk.
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// LogChunk is a piece of one output stream of one attempt. Workers write
+// output in chunks while the command runs; Offset is the position of Data
+// in the full stream, so chunks dropped to respect the size cap show up as
+// gaps between one chunk's end and the next chunk's Offset.
+type LogChunk struct {
+	ID        uint      `json:"-" gorm:"primaryKey"`
+	JobID     string    `json:"job_id" gorm:"size:64;not null;index:idx_job_logs_attempt"`
+	Attempt   int       `json:"attempt" gorm:"not null;index:idx_job_logs_attempt"`
+	Stream    string    `json:"stream" gorm:"size:8;not null"`
+	Offset    int64     `json:"offset" gorm:"not null"`
+	Data      string    `json:"data"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (LogChunk) TableName() string {
+	return "job_logs"
+}
+
+// End is the stream offset just past the chunk.
+func (c *LogChunk) End() int64 {
+	return c.Offset + int64(len(c.Data))
+}
+
+// TruncationMarker is printed where n bytes of a stream were dropped.
+func TruncationMarker(n int64) string {
+	return fmt.Sprintf("\n[... %d bytes truncated ...]\n", n)
+}
+
+// LogGaps tracks where each stream of an attempt left off while chunks are
+// read in order, to tell where truncation dropped output.
+type LogGaps map[string]int64
+
+// Skipped returns how many bytes of c's stream were dropped between the
+// previous chunk and c, and moves past c.
+func (g LogGaps) Skipped(c *LogChunk) int64 {
+	key := streamKey(c)
+	skipped := c.Offset - g[key]
+	g[key] = c.End()
+	return max(skipped, 0)
+}
+
+func streamKey(c *LogChunk) string {
+	return fmt.Sprintf("%d/%s", c.Attempt, c.Stream)
+}
+
+// LogLine is one line of output, stamped with the time the chunk holding
+// its first byte was stored.
+type LogLine struct {
+	Time    time.Time `json:"time"`
+	Attempt int       `json:"attempt"`
+	Stream  string    `json:"stream"`
+	Text    string    `json:"text"`
+}
+
+// LineSplitter turns log chunks, read in order, into lines. A chunk can end
+// mid-line; the start of the line is held until its newline arrives or
+// Flush is called. Output dropped by truncation becomes a marker line.
+type LineSplitter struct {
+	gaps    LogGaps
+	partial map[string]*LogLine
+}
+
+// Add returns the lines completed by c.
+func (s *LineSplitter) Add(c *LogChunk) []LogLine {
+	if s.gaps == nil {
+		s.gaps = LogGaps{}
+		s.partial = map[string]*LogLine{}
+	}
+	key := streamKey(c)
+	var lines []LogLine
+	if n := s.gaps.Skipped(c); n > 0 {
+		if p := s.partial[key]; p != nil {
+			lines = append(lines, *p)
+			delete(s.partial, key)
+		}
+		lines = append(lines, LogLine{Time: c.CreatedAt, Attempt: c.Attempt, Stream: c.Stream, Text: strings.TrimSpace(TruncationMarker(n))})
+	}
+	for data := c.Data; data != ""; {
+		p := s.partial[key]
+		if p == nil {
+			p = &LogLine{Time: c.CreatedAt, Attempt: c.Attempt, Stream: c.Stream}
+			s.partial[key] = p
+		}
+		line, rest, complete := strings.Cut(data, "\n")
+		p.Text += line
+		if !complete {
+			break
+		}
+		lines = append(lines, *p)
+		delete(s.partial, key)
+		data = rest
+	}
+	return lines
+}
+
+// Flush returns the unfinished lines, for output that ends without a
+// newline.
+func (s *LineSplitter) Flush() []LogLine {
+	var lines []LogLine
+	for _, p := range s.partial {
+		lines = append(lines, *p)
+	}
+	clear(s.partial)
+	slices.SortFunc(lines, func(a, b LogLine) int {
+		return cmp.Or(cmp.Compare(a.Attempt, b.Attempt), strings.Compare(a.Stream, b.Stream))
+	})
+	return lines
+}
@@ -8,26 +8,149 @@ import (
 
 type JobState string
 
+// DefaultQueue is the queue jobs land in when none is given.
+const DefaultQueue = "default"
+
+// DefaultMaxRetries is used when a job does not set max_retries.
+const DefaultMaxRetries = 3
+
 const (
 	StatePending    JobState = "pending"
 	StateProcessing JobState = "processing"
 	StateCompleted  JobState = "completed"
 	StateFailed     JobState = "failed"
 	StateDead       JobState = "dead"
+	// StateBlocked jobs wait for the jobs in DependsOn to complete before
+	// they become pending.
+	StateBlocked JobState = "blocked"
+	// StateCancelled jobs were stopped by "queuectl cancel" and never run again.
+	StateCancelled JobState = "cancelled"
+)
+
+// Finished reports whether a job in state s will never run again.
+func (s JobState) Finished() bool {
+	return s == StateCompleted || s == StateDead || s == StateCancelled
+}
+
+// Values for Job.UniqueScope, deciding how long a job keeps its
+// IdempotencyKey to itself.
+const (
+	// UniqueForever holds the key for good (the default unless UniqueFor is set).
+	UniqueForever = "forever"
+	// UniquePending holds the key until the job starts running.
+	UniquePending = "pending"
+	// UniqueUnfinished holds the key until the job completes, dies or is cancelled.
+	UniqueUnfinished = "unfinished"
+	// UniqueWindow holds the key for UniqueFor after the job was created
+	// (the default when UniqueFor is set).
+	UniqueWindow = "window"
+)
+
+// Values for Job.OnParentDead, deciding what happens to a blocked job when
+// one of its dependencies ends up in the DLQ.
+const (
+	// OnParentDeadCascade moves the job to the DLQ as well (the default).
+	OnParentDeadCascade = "cascade"
+	// OnParentDeadSkip ignores the dead dependency; the job runs once its
+	// remaining dependencies complete.
+	OnParentDeadSkip = "skip"
 )
 
 type Job struct {
-	ID         string         `json:"id" gorm:"primaryKey;size:64"`
-	Command    string         `json:"command" gorm:"not null"`
-	State      JobState       `json:"state" gorm:"index;not null;default:'pending'"`
-	Attempts   int32          `json:"attempts" gorm:"not null;default:0"`
-	MaxRetries int32          `json:"max_retries" gorm:"not null;default:3"`
-	Output     string         `json:"output"`
-	Duration   float64        `json:"duration"`
-	Priority int `json:"priority" gorm:"default:0;index"` 
-	RunAt      *time.Time     `json:"run_at,omitempty"`
-	LastError  *string        `json:"last_error,omitempty"`
-	CreatedAt  time.Time      `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt  time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
-	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+	ID         string     `json:"id" gorm:"primaryKey;size:64"`
+	Command    string     `json:"command" gorm:"not null"`
+	State      JobState   `json:"state" gorm:"index;not null;default:'pending'"`
+	Queue      string     `json:"queue" gorm:"size:64;index;not null;default:'default'"`
+	Attempts   int32      `json:"attempts" gorm:"not null;default:0"`
+	MaxRetries int32      `json:"max_retries" gorm:"not null;default:3"`
+	Output     string     `json:"output"`
+	Duration   float64    `json:"duration"`
+	Priority   int        `json:"priority" gorm:"default:0;index"`
+	RunAt      *time.Time `json:"run_at,omitempty"`
+	LastError  *string    `json:"last_error,omitempty"`
+	// Args, Env, Cwd and Stdin describe the process beyond Command; ExecMode
+	// picks between a shell and running the program directly (see ExecShell
+	// and ExecDirect). Env is added to the worker's own environment.
+	Args     []string          `json:"args,omitempty" gorm:"serializer:json"`
+	Env      map[string]string `json:"env,omitempty" gorm:"serializer:json"`
+	Cwd      string            `json:"cwd,omitempty"`
+	Stdin    string            `json:"stdin,omitempty"`
+	ExecMode string            `json:"exec_mode,omitempty" gorm:"size:8"`
+	// ExitCode is the exit status of the last run; nil when the command
+	// never exited on its own (timeout, cancellation) or has not run yet.
+	ExitCode *int `json:"exit_code,omitempty"`
+	// RetryOnExitCodes, when set, limits retries to these exit codes (plus
+	// ExitTempFail); FailFastExitCodes send the job straight to the DLQ.
+	RetryOnExitCodes  []int `json:"retry_on_exit_codes,omitempty" gorm:"serializer:json"`
+	FailFastExitCodes []int `json:"fail_fast_exit_codes,omitempty" gorm:"serializer:json"`
+	// DependsOn lists jobs that must complete before this one may run.
+	DependsOn    []string `json:"depends_on,omitempty" gorm:"serializer:json"`
+	OnParentDead string   `json:"on_parent_dead,omitempty" gorm:"size:16"`
+	// Timeout overrides the worker's --timeout for this job.
+	Timeout Duration `json:"timeout,omitempty" gorm:"not null;default:0"`
+	// Backoff overrides the worker's exponential --backoff-base retry delay.
+	Backoff *Backoff `json:"backoff,omitempty" gorm:"serializer:json"`
+	// IdempotencyKey deduplicates enqueues: while the job holding a key is
+	// within its UniqueScope, enqueueing another job with the same key
+	// returns the existing job instead of inserting a new one.
+	IdempotencyKey string `json:"idempotency_key,omitempty" gorm:"size:255;index"`
+	// UniqueScope is how long the key is held (see the Unique* constants);
+	// UniqueFor is the window for UniqueWindow.
+	UniqueScope string   `json:"unique_scope,omitempty" gorm:"size:16"`
+	UniqueFor   Duration `json:"unique_for,omitempty" gorm:"not null;default:0"`
+	// DedupeKey carries IdempotencyKey under a unique index while the key is
+	// held, and is cleared once a newer job takes the key over.
+	DedupeKey *string `json:"-" gorm:"size:255;uniqueIndex"`
+	// WorkerID and LeaseExpiresAt record who owns a processing job and until
+	// when; a job whose lease runs out is reclaimed by the reaper.
+	WorkerID       *string    `json:"worker_id,omitempty" gorm:"size:255;index"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty" gorm:"index"`
+	// CancelRequested asks the worker running this job to kill it.
+	CancelRequested bool           `json:"cancel_requested,omitempty" gorm:"not null;default:false"`
+	CreatedAt       time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time      `json:"updated_at" gorm:"autoUpdateTime;index"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Dependency is one edge of a workflow DAG: JobID waits for ParentID.
+// Edges are stored separately from Job.DependsOn so a finishing job can find
+// its dependents without scanning every blocked job.
+type Dependency struct {
+	JobID    string `gorm:"primaryKey;size:64"`
+	ParentID string `gorm:"primaryKey;size:64;index"`
+}
+
+func (Dependency) TableName() string {
+	return "job_dependencies"
+}
+
+// Attempt is one execution of a job. Workers open a row when they start
+// the command and fill in the result when it ends, so the table keeps the
+// full history that retries overwrite on the job itself.
+type Attempt struct {
+	ID         uint       `json:"-" gorm:"primaryKey"`
+	JobID      string     `json:"job_id" gorm:"size:64;not null;index"`
+	Number     int        `json:"number" gorm:"not null"`
+	WorkerID   string     `json:"worker_id" gorm:"size:255"`
+	StartedAt  time.Time  `json:"started_at" gorm:"not null"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	// State is the job state this attempt led to; empty while it runs or
+	// when its result was discarded.
+	State  JobState `json:"state,omitempty" gorm:"size:16"`
+	Stdout string   `json:"stdout,omitempty"`
+	Stderr string   `json:"stderr,omitempty"`
+	Error  *string  `json:"error,omitempty"`
+}
+
+func (Attempt) TableName() string {
+	return "job_attempts"
+}
+
+// Duration is how long the attempt ran, or has been running so far.
+func (a *Attempt) Duration() time.Duration {
+	if a.FinishedAt == nil {
+		return time.Since(a.StartedAt)
+	}
+	return a.FinishedAt.Sub(a.StartedAt)
 }
@@ -0,0 +1,130 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Values for Job.ExecMode, deciding how a worker starts the job.
+const (
+	// ExecShell runs Command with "bash -c"; Args become $1, $2, ... of the
+	// script. This is the default for jobs without Args.
+	ExecShell = "shell"
+	// ExecDirect runs a program without a shell: Command is the program and
+	// Args its arguments, or Args alone is the full argv when Command is
+	// empty. This is the default for jobs with Args.
+	ExecDirect = "exec"
+)
+
+// Mode returns the job's exec mode, filling in the default.
+func (j *Job) Mode() string {
+	if j.ExecMode != "" {
+		return j.ExecMode
+	}
+	if len(j.Args) > 0 {
+		return ExecDirect
+	}
+	return ExecShell
+}
+
+// Argv is the program and arguments a worker runs for the job.
+func (j *Job) Argv() []string {
+	if j.Mode() == ExecShell {
+		// The extra "bash" is $0, so Args start at $1.
+		return append([]string{"bash", "-c", j.Command, "bash"}, j.Args...)
+	}
+	if j.Command == "" {
+		return j.Args
+	}
+	return append([]string{j.Command}, j.Args...)
+}
+
+// ValidatePayload checks that the job can be run in its exec mode.
+func (j *Job) ValidatePayload() error {
+	switch j.Mode() {
+	case ExecShell:
+		if strings.TrimSpace(j.Command) == "" {
+			return errors.New("job has no command")
+		}
+	case ExecDirect:
+		if argv := j.Argv(); len(argv) == 0 || argv[0] == "" {
+			return errors.New("job has no program to run (set command or args)")
+		}
+	default:
+		return fmt.Errorf("invalid exec_mode %q (want %s or %s)", j.ExecMode, ExecShell, ExecDirect)
+	}
+	for name := range j.Env {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+	}
+	return nil
+}
+
+// Normalize validates a new job and fills in the defaults every way of
+// creating jobs shares: enqueue, the web API and schedules. A new job starts
+// pending, so other states are refused, and the fields that record a run
+// (attempts, worker, lease, exit code and the like) are cleared. It leaves
+// the ID to the caller.
+func (j *Job) Normalize() error {
+	if j.State != "" && j.State != StatePending {
+		return fmt.Errorf("invalid state %q: new jobs start pending", j.State)
+	}
+	if err := j.ValidatePayload(); err != nil {
+		return err
+	}
+	j.State = StatePending
+	j.clearRunState()
+	if j.MaxRetries == 0 {
+		j.MaxRetries = DefaultMaxRetries
+	}
+	if j.Queue == "" {
+		j.Queue = DefaultQueue
+	}
+	j.CreatedAt = time.Now().UTC()
+	j.UpdatedAt = j.CreatedAt
+	return nil
+}
+
+// clearRunState drops the fields workers and the store own, so a new job
+// cannot look claimed, already run or being cancelled.
+func (j *Job) clearRunState() {
+	j.Attempts = 0
+	j.Output = ""
+	j.Duration = 0
+	j.LastError = nil
+	j.ExitCode = nil
+	j.WorkerID = nil
+	j.LeaseExpiresAt = nil
+	j.CancelRequested = false
+}
+
+// CommandLine renders what the job runs, for listings and logs. Arguments
+// are quoted where needed, so exec jobs read like the shell command they
+// replace.
+func (j *Job) CommandLine() string {
+	var parts []string
+	if j.Mode() == ExecShell {
+		if len(j.Args) == 0 {
+			return j.Command
+		}
+		parts = append(parts, j.Command, "--")
+	} else if j.Command != "" {
+		parts = append(parts, quoteArg(j.Command))
+	}
+	for _, arg := range j.Args {
+		parts = append(parts, quoteArg(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteArg single-quotes arg unless it is made of characters no shell treats
+// specially.
+func quoteArg(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@%+,") == "" {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
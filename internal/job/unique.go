@@ -0,0 +1,53 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// NormalizeUnique validates the deduplication fields and fills in the
+// default scope. Jobs without an IdempotencyKey must not set a scope.
+func (j *Job) NormalizeUnique() error {
+	if j.IdempotencyKey == "" {
+		if j.UniqueScope != "" || j.UniqueFor != 0 {
+			return errors.New("unique_scope and unique_for need an idempotency_key")
+		}
+		return nil
+	}
+	if j.UniqueFor < 0 {
+		return errors.New("unique_for cannot be negative")
+	}
+	if j.UniqueScope == "" {
+		j.UniqueScope = UniqueForever
+		if j.UniqueFor > 0 {
+			j.UniqueScope = UniqueWindow
+		}
+	}
+	switch j.UniqueScope {
+	case UniqueForever, UniquePending, UniqueUnfinished:
+	case UniqueWindow:
+		if j.UniqueFor <= 0 {
+			return errors.New("unique_scope window needs a positive unique_for")
+		}
+	default:
+		return fmt.Errorf("invalid unique_scope %q (want %s, %s, %s or %s)",
+			j.UniqueScope, UniqueForever, UniquePending, UniqueUnfinished, UniqueWindow)
+	}
+	return nil
+}
+
+// HoldsUniqueKey reports whether j still owns its IdempotencyKey at now, so
+// that a new job with the same key is a duplicate of it.
+func (j *Job) HoldsUniqueKey(now time.Time) bool {
+	switch j.UniqueScope {
+	case UniquePending:
+		return j.State == StatePending || j.State == StateBlocked
+	case UniqueUnfinished:
+		return !j.State.Finished()
+	case UniqueWindow:
+		return now.Before(j.CreatedAt.Add(time.Duration(j.UniqueFor)))
+	default:
+		return true
+	}
+}
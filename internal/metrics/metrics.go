@@ -0,0 +1,296 @@
+// Package metrics keeps counters, gauges and histograms and serves them in
+// the Prometheus text exposition format (version 0.0.4). It is a small
+// stand-in for the Prometheus client library: no background goroutines, no
+// network access, and output that is stable enough to compare in tests.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DurationBuckets are histogram upper bounds, in seconds, suited to job run
+// and wait times: from a tenth of a second to an hour.
+var DurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600}
+
+// Registry holds metric families in the order they were created.
+type Registry struct {
+	mu         sync.Mutex
+	families   []*family
+	collectors []func() error
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+type family struct {
+	name    string
+	help    string
+	kind    string // counter, gauge or histogram
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	labels []string
+	value  float64
+	// Histograms only: observations per bucket (not cumulative), plus the
+	// sum and count of all observations.
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (r *Registry) add(name, help, kind string, buckets []float64, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.families {
+		if f.name == name {
+			panic("metrics: " + name + " registered twice")
+		}
+	}
+	f := &family{name: name, help: help, kind: kind, labels: labels, buckets: buckets, series: map[string]*series{}}
+	if len(labels) == 0 {
+		// A metric without labels is reported as 0 before its first update.
+		f.get(nil)
+	}
+	r.families = append(r.families, f)
+	return f
+}
+
+// OnScrape registers fn to run before every scrape, to refresh metrics that
+// mirror state kept elsewhere, such as the database. An error fails the
+// scrape.
+func (r *Registry) OnScrape(fn func() error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, fn)
+}
+
+// get returns the series for the label values, creating it. Callers hold r.mu.
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s := f.series[key]
+	if s == nil {
+		s = &series{labels: slices.Clone(values)}
+		if f.buckets != nil {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Counter is a family of values that only go up.
+type Counter struct {
+	r *Registry
+	f *family
+}
+
+// Counter creates a counter family with the given label names.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r, r.add(name, help, "counter", nil, labels)}
+}
+
+// Inc adds one to the series with the given label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the series.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.f.name + " cannot decrease")
+	}
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.f.get(labelValues).value += v
+}
+
+// Set replaces the series' value, for counters that mirror a total kept
+// elsewhere.
+func (c *Counter) Set(v float64, labelValues ...string) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.f.get(labelValues).value = v
+}
+
+// Reset drops every series, so that a refresh does not keep label values
+// that no longer exist.
+func (c *Counter) Reset() {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	clear(c.f.series)
+}
+
+// Gauge is a family of values that go up and down.
+type Gauge struct {
+	r *Registry
+	f *family
+}
+
+// Gauge creates a gauge family with the given label names.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r, r.add(name, help, "gauge", nil, labels)}
+}
+
+// Set replaces the series' value.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.r.mu.Lock()
+	defer g.r.mu.Unlock()
+	g.f.get(labelValues).value = v
+}
+
+// Add adds v, which may be negative, to the series.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.r.mu.Lock()
+	defer g.r.mu.Unlock()
+	g.f.get(labelValues).value += v
+}
+
+// Reset drops every series.
+func (g *Gauge) Reset() {
+	g.r.mu.Lock()
+	defer g.r.mu.Unlock()
+	clear(g.f.series)
+}
+
+// Histogram is a family of observation counts in buckets.
+type Histogram struct {
+	r *Registry
+	f *family
+}
+
+// Histogram creates a histogram family; buckets are the ascending upper
+// bounds, without +Inf.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 || !slices.IsSorted(buckets) {
+		panic("metrics: " + name + " needs ascending buckets")
+	}
+	return &Histogram{r, r.add(name, help, "histogram", slices.Clone(buckets), labels)}
+}
+
+// Observe records one value in the series.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+	s := h.f.get(labelValues)
+	if i, _ := slices.BinarySearch(h.f.buckets, v); i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+// Write runs the OnScrape functions and writes every family in the text
+// format, series sorted by label values.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.Unlock()
+	for _, fn := range collectors {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	for _, f := range r.families {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.kind)
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			f.write(bw, f.series[key])
+		}
+	}
+	return bw.Flush()
+}
+
+func (f *family) write(w io.Writer, s *series) {
+	if f.kind != "histogram" {
+		fmt.Fprintf(w, "%s%s %s\n", f.name, labelSet(f.labels, s.labels), formatValue(s.value))
+		return
+	}
+	names := append(slices.Clone(f.labels), "le")
+	var cumulative uint64
+	for i, bound := range f.buckets {
+		cumulative += s.counts[i]
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, labelSet(names, append(slices.Clone(s.labels), formatValue(bound))), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, labelSet(names, append(slices.Clone(s.labels), "+Inf")), s.count)
+	fmt.Fprintf(w, "%s_sum%s %s\n", f.name, labelSet(f.labels, s.labels), formatValue(s.sum))
+	fmt.Fprintf(w, "%s_count%s %d\n", f.name, labelSet(f.labels, s.labels), s.count)
+}
+
+// ServeHTTP answers a scrape. Registry is an http.Handler, so it can be
+// mounted at /metrics directly.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		http.Error(w, "collecting metrics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	io.WriteString(w, b.String())
+}
+
+func labelSet(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,85 @@
+package metrics_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"queuectl.backend/internal/metrics"
+)
+
+func TestWriteTextFormat(t *testing.T) {
+	reg := metrics.NewRegistry()
+	jobs := reg.Gauge("jobs", "Jobs by state.", "queue", "state")
+	errs := reg.Counter("claim_errors_total", "Failed claims.")
+	wait := reg.Histogram("wait_seconds", "Time waited.", []float64{1, 5}, "queue")
+
+	jobs.Set(3, "default", "pending")
+	jobs.Set(1, `we"ird`+"\n", "dead")
+	wait.Observe(0.5, "default")
+	wait.Observe(1, "default")
+	wait.Observe(7, "default")
+
+	var b strings.Builder
+	if err := reg.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP jobs Jobs by state.
+# TYPE jobs gauge
+jobs{queue="default",state="pending"} 3
+jobs{queue="we\"ird\n",state="dead"} 1
+# HELP claim_errors_total Failed claims.
+# TYPE claim_errors_total counter
+claim_errors_total 0
+# HELP wait_seconds Time waited.
+# TYPE wait_seconds histogram
+wait_seconds_bucket{queue="default",le="1"} 2
+wait_seconds_bucket{queue="default",le="5"} 2
+wait_seconds_bucket{queue="default",le="+Inf"} 3
+wait_seconds_sum{queue="default"} 8.5
+wait_seconds_count{queue="default"} 3
+`
+	if b.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	errs.Inc()
+	jobs.Reset()
+	b.Reset()
+	reg.Write(&b)
+	if !strings.Contains(b.String(), "claim_errors_total 1\n") || strings.Contains(b.String(), "jobs{") {
+		t.Fatalf("unexpected output after update:\n%s", b.String())
+	}
+}
+
+func TestScrapeRunsCollectors(t *testing.T) {
+	reg := metrics.NewRegistry()
+	total := reg.Counter("enqueued_total", "Jobs enqueued.", "queue")
+	n := 0
+	fail := false
+	reg.OnScrape(func() error {
+		if fail {
+			return errors.New("database is gone")
+		}
+		n++
+		total.Set(float64(n*10), "default")
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != 200 || rec.Header().Get("Content-Type") != metrics.ContentType {
+		t.Fatalf("unexpected response %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), `enqueued_total{queue="default"} 10`) {
+		t.Fatalf("collector did not run:\n%s", rec.Body.String())
+	}
+
+	fail = true
+	rec = httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != 500 {
+		t.Fatalf("expected a failed collector to fail the scrape, got %d", rec.Code)
+	}
+}
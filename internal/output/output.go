@@ -0,0 +1,266 @@
+// Package output renders command results as JSON, YAML, CSV or an aligned
+// table. Every format is driven by the values' JSON encoding, so field names
+// and omitempty behave the same everywhere and match the job JSON accepted
+// by enqueue.
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format selects a renderer.
+type Format string
+
+const (
+	// Text is each command's own human-readable output (the default).
+	Text  Format = ""
+	JSON  Format = "json"
+	YAML  Format = "yaml"
+	Table Format = "table"
+	CSV   Format = "csv"
+)
+
+// Parse validates a --output value.
+func Parse(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case Text, JSON, YAML, Table, CSV:
+		return f, nil
+	case "text":
+		return Text, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q (want json, yaml, table or csv)", s)
+	}
+}
+
+// Write renders v to w. v is a struct, a map or a slice of them; table and
+// CSV print one row per element with a column per JSON field.
+func Write(w io.Writer, f Format, v any) error {
+	if f == JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(v)
+	}
+
+	doc, err := decode(v)
+	if err != nil {
+		return err
+	}
+	switch f {
+	case YAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(toNode(doc)); err != nil {
+			return err
+		}
+		return enc.Close()
+	case Table, CSV:
+		header, rows := records(doc)
+		if len(header) == 0 {
+			return nil
+		}
+		if f == CSV {
+			cw := csv.NewWriter(w)
+			cw.Write(header)
+			cw.WriteAll(rows)
+			return cw.Error()
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for i, h := range header {
+			header[i] = strings.ToUpper(h)
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, row := range rows {
+			for i, cell := range row {
+				row[i] = strings.ReplaceAll(strings.ReplaceAll(cell, "\n", `\n`), "\t", " ")
+			}
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("format %q has no generic renderer", f)
+	}
+}
+
+// field is one key of a decoded JSON object; objects keep their key order so
+// YAML and table columns follow the struct field order.
+type field struct {
+	key   string
+	value any
+}
+
+type object []field
+
+// decode round-trips v through JSON into objects, []any and scalars.
+func decode(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return decodeValue(dec)
+}
+
+func decodeValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		var obj object
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, field{key.(string), value})
+		}
+		_, err := dec.Token() // closing }
+		return obj, err
+	case json.Delim('['):
+		list := []any{}
+		for dec.More() {
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := dec.Token() // closing ]
+		return list, err
+	default:
+		return tok, nil
+	}
+}
+
+func toNode(v any) *yaml.Node {
+	switch v := v.(type) {
+	case object:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		for _, f := range v {
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: f.key}, toNode(f.value))
+		}
+		return n
+	case []any:
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range v {
+			n.Content = append(n.Content, toNode(item))
+		}
+		return n
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case string:
+		n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+		if strings.Contains(v, "\n") {
+			n.Style = yaml.LiteralStyle
+		}
+		return n
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(v.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.String()}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(v)}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(v)}
+	}
+}
+
+// records flattens doc into a header and rows. Columns appear in the order
+// they are first seen, so fields dropped by omitempty leave empty cells.
+func records(doc any) ([]string, [][]string) {
+	items, ok := doc.([]any)
+	if !ok {
+		items = []any{doc}
+	}
+
+	var header []string
+	index := map[string]int{}
+	var objects []object
+	for _, item := range items {
+		obj, ok := item.(object)
+		if !ok {
+			obj = object{{"value", item}}
+		}
+		for _, f := range obj {
+			if _, seen := index[f.key]; !seen {
+				index[f.key] = len(header)
+				header = append(header, f.key)
+			}
+		}
+		objects = append(objects, obj)
+	}
+
+	rows := make([][]string, 0, len(objects))
+	for _, obj := range objects {
+		row := make([]string, len(header))
+		for _, f := range obj {
+			row[index[f.key]] = cell(f.value)
+		}
+		rows = append(rows, row)
+	}
+	return header, rows
+}
+
+// cell formats a scalar as-is and nested values as compact JSON.
+func cell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	default:
+		b, _ := json.Marshal(plain(v))
+		return string(b)
+	}
+}
+
+// plain converts decoded objects back into values encoding/json can marshal
+// with their key order intact.
+func plain(v any) any {
+	switch v := v.(type) {
+	case object:
+		var buf bytes.Buffer
+		buf.WriteByte('{')
+		for i, f := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			k, _ := json.Marshal(f.key)
+			val, _ := json.Marshal(plain(f.value))
+			buf.Write(k)
+			buf.WriteByte(':')
+			buf.Write(val)
+		}
+		buf.WriteByte('}')
+		return json.RawMessage(buf.Bytes())
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = plain(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
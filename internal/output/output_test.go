@@ -0,0 +1,45 @@
+package output_test
+
+import (
+	"strings"
+	"testing"
+
+	"queuectl.backend/internal/output"
+)
+
+type row struct {
+	ID     string   `json:"id"`
+	Count  int      `json:"count"`
+	Note   string   `json:"note,omitempty"`
+	Labels []string `json:"labels,omitempty"`
+}
+
+func render(t *testing.T, f output.Format, v any) string {
+	t.Helper()
+	var b strings.Builder
+	if err := output.Write(&b, f, v); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestFormats(t *testing.T) {
+	rows := []row{{ID: "a", Count: 1}, {ID: "b", Count: 2, Note: "x, y", Labels: []string{"l"}}}
+
+	if got, want := render(t, output.CSV, rows), "id,count,note,labels\na,1,,\nb,2,\"x, y\",\"[\"\"l\"\"]\"\n"; got != want {
+		t.Errorf("csv:\n%s\nwant:\n%s", got, want)
+	}
+	if got, want := render(t, output.Table, rows), "ID  COUNT  NOTE  LABELS\na   1            \nb   2      x, y  [\"l\"]\n"; got != want {
+		t.Errorf("table:\n%q\nwant:\n%q", got, want)
+	}
+	if got, want := render(t, output.YAML, rows[1]), "id: b\ncount: 2\nnote: x, y\nlabels:\n  - l\n"; got != want {
+		t.Errorf("yaml:\n%s\nwant:\n%s", got, want)
+	}
+	if got := render(t, output.JSON, rows[0]); got != "{\n  \"id\": \"a\",\n  \"count\": 1\n}\n" {
+		t.Errorf("json: %s", got)
+	}
+
+	if _, err := output.Parse("xml"); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
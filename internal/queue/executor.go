@@ -8,7 +8,12 @@ import (
 	"time"
 )
 
-// ExecResult stores detailed information about a command execution.
+// ErrJobCancelled is the ExecResult error for a command killed because its
+// job was cancelled.
+var ErrJobCancelled = errors.New("job cancelled")
+
+// ExecResult stores detailed information about a command execution. Workers
+// store the full output in job_logs; Stdout and Stderr only hold its end.
 type ExecResult struct {
 	ExitCode int
 	Stdout   string
@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/store"
+)
+
+func newTestWorker(t *testing.T, cfg WorkerConfig) (*Worker, store.JobStore) {
+	t.Helper()
+	repo, err := store.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	cfg.ID = "test"
+	return NewWorker(repo, cfg), repo
+}
+
+func TestExecCommandHonorsPayload(t *testing.T) {
+	w, _ := newTestWorker(t, WorkerConfig{})
+	dir := t.TempDir()
+
+	// Direct exec: arguments reach the program verbatim, without a shell.
+	r := w.ExecCommand(context.Background(), &job.Job{
+		ID:    "payload",
+		Args:  []string{"sh", "-c", `printf '%s|%s|%s|' "$GREETING" "$(pwd)" "$1"; cat`, "sh", "$HOME; rm -rf /"},
+		Env:   map[string]string{"GREETING": "hi there"},
+		Cwd:   dir,
+		Stdin: "from stdin",
+	}, 1, 5*time.Second)
+	if r.Err != nil || r.ExitCode != 0 {
+		t.Fatalf("exec failed: %+v", r)
+	}
+	if want := "hi there|" + dir + "|$HOME; rm -rf /|from stdin"; r.Stdout != want {
+		t.Fatalf("stdout = %q, want %q", r.Stdout, want)
+	}
+
+	// Shell mode passes args as positional parameters.
+	r = w.ExecCommand(context.Background(), &job.Job{ID: "shell", Command: `echo "$1-$2"`, Args: []string{"a b", "c"}, ExecMode: job.ExecShell}, 1, 5*time.Second)
+	if r.Stdout != "a b-c\n" {
+		t.Fatalf("shell stdout = %q", r.Stdout)
+	}
+}
+
+func TestExecCommandCapsStoredOutput(t *testing.T) {
+	const limit = 64 * 1024
+	w, repo := newTestWorker(t, WorkerConfig{MaxLogSize: limit})
+
+	// 100000 numbered lines, about 690 KB, plus a line on stderr.
+	r := w.ExecCommand(context.Background(), &job.Job{ID: "chatty", Command: "seq 100000; echo oops >&2"}, 1, 10*time.Second)
+	if r.Err != nil {
+		t.Fatalf("exec failed: %+v", r)
+	}
+	if len(r.Stdout) > previewSize+100 || !strings.HasSuffix(r.Stdout, "99999\n100000\n") {
+		t.Fatalf("preview should be the bounded end of stdout, got %d bytes", len(r.Stdout))
+	}
+	if r.Stderr != "oops\n" {
+		t.Fatalf("stderr = %q", r.Stderr)
+	}
+
+	chunks, err := repo.ReadLogs(store.LogQuery{JobID: "chatty", Attempt: 1, Stream: job.StreamStdout})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text strings.Builder
+	var stored, dropped int64
+	gaps := job.LogGaps{}
+	for _, c := range chunks {
+		if n := gaps.Skipped(&c); n > 0 {
+			dropped += n
+			text.WriteString(job.TruncationMarker(n))
+		}
+		stored += int64(len(c.Data))
+		text.WriteString(c.Data)
+	}
+	if stored > limit || stored < limit*3/4 {
+		t.Fatalf("stored %d bytes, want close to the %d cap", stored, limit)
+	}
+	out := text.String()
+	if !strings.HasPrefix(out, "1\n2\n3\n") || !strings.HasSuffix(out, "99999\n100000\n") || !strings.Contains(out, "bytes truncated") {
+		t.Fatalf("log should keep head and tail around a marker:\n%s...%s", out[:40], out[len(out)-40:])
+	}
+	if last := chunks[len(chunks)-1]; stored+dropped != last.End() {
+		t.Fatalf("stored + dropped = %d, stream length %d", stored+dropped, last.End())
+	}
+
+	errChunks, err := repo.ReadLogs(store.LogQuery{JobID: "chatty", Stream: job.StreamStderr})
+	if err != nil || len(errChunks) != 1 || errChunks[0].Data != "oops\n" {
+		t.Fatalf("stderr log = %+v, %v", errChunks, err)
+	}
+}
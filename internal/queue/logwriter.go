@@ -0,0 +1,218 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/store"
+)
+
+// DefaultMaxLogSize caps the output stored per stream and attempt.
+const DefaultMaxLogSize = 1 << 20
+
+// logChunkSize is the most output a logWriter gathers before storing it.
+const logChunkSize = 32 * 1024
+
+// logFlushInterval is how often output gathered so far is stored anyway, so
+// "queuectl logs --follow" sees running jobs.
+const logFlushInterval = time.Second
+
+// logStampResolution is how far apart writes of whole lines may be and still
+// share a chunk, and so a timestamp.
+const logStampResolution = 250 * time.Millisecond
+
+// previewSize is how much of the end of each stream is kept on the job and
+// attempt rows, for listings and error messages.
+const previewSize = 4 * 1024
+
+// logWriter streams one output stream of an attempt into job_logs while the
+// command runs, so output never piles up in memory. The first half of the
+// size cap is kept from the start of the stream and the second half from
+// its end: once the tail grows past its share, its oldest chunks are
+// deleted. Readers see the dropped middle as a gap in the chunk offsets.
+type logWriter struct {
+	repo      store.JobStore
+	jobID     string
+	attempt   int
+	stream    string
+	headMax   int64
+	tailMax   int64
+	chunkSize int
+
+	mu      sync.Mutex
+	pending []byte
+	// pendingSince is when the first pending byte was written; chunks are
+	// stamped with it so line times do not lag by the flush interval.
+	pendingSince time.Time
+	offset       int64 // bytes of the stream seen so far
+	tail         []storedChunk
+	tailSize     int64
+	preview      []byte
+	err          error
+}
+
+type storedChunk struct {
+	id   uint
+	size int64
+}
+
+func newLogWriter(repo store.JobStore, jobID string, attempt int, stream string, limit int64) *logWriter {
+	head := limit / 2
+	tail := limit - head
+	return &logWriter{
+		repo:    repo,
+		jobID:   jobID,
+		attempt: attempt,
+		stream:  stream,
+		headMax: head,
+		tailMax: tail,
+		// Small enough that dropping the oldest tail chunk keeps most of the tail.
+		chunkSize: int(max(min(tail/4, logChunkSize), 64)),
+	}
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	written := len(p)
+	w.preview = appendTail(w.preview, p, previewSize)
+	if n := len(w.pending); n > 0 && w.pending[n-1] == '\n' && time.Since(w.pendingSince) > logStampResolution {
+		w.flush(false)
+	}
+	for len(p) > 0 {
+		n := min(len(p), w.chunkSize-len(w.pending))
+		// Head chunks end exactly at the head boundary.
+		if end := w.offset; end < w.headMax {
+			n = int(min(int64(n), w.headMax-end))
+		}
+		if len(w.pending) == 0 {
+			w.pendingSince = time.Now().UTC()
+		}
+		w.pending = append(w.pending, p[:n]...)
+		w.offset += int64(n)
+		p = p[n:]
+		if len(w.pending) >= w.chunkSize || w.offset == w.headMax {
+			w.flush(false)
+		}
+	}
+	// Storage errors are reported by Close; the command must not see them.
+	return written, nil
+}
+
+// flush stores the pending bytes as a chunk. Unless final, an incomplete
+// UTF-8 sequence at the end waits for the next chunk.
+func (w *logWriter) flush(final bool) {
+	data := w.pending
+	if !final {
+		data = data[:completeRunes(data)]
+	}
+	if len(data) == 0 {
+		return
+	}
+	start := w.offset - int64(len(w.pending))
+	chunk := job.LogChunk{
+		JobID:     w.jobID,
+		Attempt:   w.attempt,
+		Stream:    w.stream,
+		Offset:    start,
+		Data:      string(data),
+		CreatedAt: w.pendingSince,
+	}
+	w.pending = append(w.pending[:0], w.pending[len(data):]...)
+
+	if err := w.repo.AppendLog(&chunk); err != nil {
+		w.fail(err)
+		return
+	}
+	if start < w.headMax {
+		return
+	}
+	w.tail = append(w.tail, storedChunk{id: chunk.ID, size: int64(len(data))})
+	w.tailSize += int64(len(data))
+	var drop []uint
+	for w.tailSize > w.tailMax && len(w.tail) > 1 {
+		drop = append(drop, w.tail[0].id)
+		w.tailSize -= w.tail[0].size
+		w.tail = w.tail[1:]
+	}
+	if err := w.repo.DeleteLogs(drop); err != nil {
+		w.fail(err)
+	}
+}
+
+func (w *logWriter) fail(err error) {
+	if w.err == nil {
+		w.err = fmt.Errorf("saving %s: %w", w.stream, err)
+	}
+}
+
+// Sync stores the output gathered so far.
+func (w *logWriter) Sync() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flush(false)
+}
+
+// Close stores the rest of the output and reports the first storage error.
+func (w *logWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flush(true)
+	return w.err
+}
+
+// Preview returns the end of the stream, marking what it leaves out.
+func (w *logWriter) Preview() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	preview := w.preview
+	if skipped := w.offset - int64(len(preview)); skipped > 0 {
+		// Start at the first full line, or at least the first full rune.
+		from := completeRunesFrom(preview)
+		if i := bytes.IndexByte(preview, '\n'); i >= 0 && i < len(preview)-1 {
+			from = i + 1
+		}
+		preview = preview[from:]
+		return fmt.Sprintf("[... %d earlier bytes not shown ...]\n", skipped+int64(from)) + string(preview)
+	}
+	return string(preview)
+}
+
+// appendTail appends p to buf and keeps only the last n bytes.
+func appendTail(buf, p []byte, n int) []byte {
+	buf = append(buf, p...)
+	if len(buf) > n {
+		buf = buf[len(buf)-n:]
+	}
+	return buf
+}
+
+// completeRunes returns the length of b without a trailing incomplete UTF-8
+// sequence.
+func completeRunes(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(b)
+}
+
+// completeRunesFrom returns the index of the first rune start in b, skipping
+// the continuation bytes of a sequence cut off at the front.
+func completeRunesFrom(b []byte) int {
+	for i := 0; i < len(b) && i < utf8.UTFMax; i++ {
+		if utf8.RuneStart(b[i]) {
+			return i
+		}
+	}
+	return 0
+}
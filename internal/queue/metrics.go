@@ -0,0 +1,112 @@
+package queue
+
+import (
+	"time"
+
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/metrics"
+)
+
+// Metrics instruments the workers of one process, for "worker start
+// --metrics-addr". Unlike store.RegisterMetrics, which reads totals for the
+// whole cluster from the database, these count what this process saw. A nil
+// *Metrics records nothing.
+type Metrics struct {
+	claimed     *metrics.Counter
+	completed   *metrics.Counter
+	failed      *metrics.Counter
+	dead        *metrics.Counter
+	cancelled   *metrics.Counter
+	retries     *metrics.Counter
+	claimErrors *metrics.Counter
+	duration    *metrics.Histogram
+	queueWait   *metrics.Histogram
+	workers     *metrics.Gauge
+}
+
+// NewMetrics registers the worker metrics in reg.
+func NewMetrics(reg *metrics.Registry) *Metrics {
+	m := &Metrics{
+		claimed:     reg.Counter("queuectl_worker_jobs_claimed_total", "Jobs claimed by this process.", "queue"),
+		completed:   reg.Counter("queuectl_worker_jobs_completed_total", "Jobs this process ran to completion.", "queue"),
+		failed:      reg.Counter("queuectl_worker_jobs_failed_total", "Runs that failed and were scheduled for a retry.", "queue"),
+		dead:        reg.Counter("queuectl_worker_jobs_dead_total", "Runs that failed and moved their job to the DLQ.", "queue"),
+		cancelled:   reg.Counter("queuectl_worker_jobs_cancelled_total", "Runs killed because their job was cancelled.", "queue"),
+		retries:     reg.Counter("queuectl_worker_job_retries_total", "Claims of jobs that had failed before.", "queue"),
+		claimErrors: reg.Counter("queuectl_worker_claim_errors_total", "Claims that failed with a storage error."),
+		duration:    reg.Histogram("queuectl_worker_job_duration_seconds", "How long job commands ran.", metrics.DurationBuckets, "queue"),
+		queueWait:   reg.Histogram("queuectl_worker_job_queue_wait_seconds", "How long jobs were runnable before they were claimed.", metrics.DurationBuckets, "queue"),
+		workers:     reg.Gauge("queuectl_workers", "Workers in this process by whether they are running a job.", "state"),
+	}
+	m.workers.Set(0, "busy")
+	m.workers.Set(0, "idle")
+	return m
+}
+
+func (m *Metrics) workerStarted() {
+	if m != nil {
+		m.workers.Add(1, "idle")
+	}
+}
+
+func (m *Metrics) workerStopped() {
+	if m != nil {
+		m.workers.Add(-1, "idle")
+	}
+}
+
+func (m *Metrics) claimFailed() {
+	if m != nil {
+		m.claimErrors.Inc()
+	}
+}
+
+// jobClaimed marks the worker busy and records how long j waited.
+func (m *Metrics) jobClaimed(j *job.Job, now time.Time) {
+	if m == nil {
+		return
+	}
+	m.workers.Add(-1, "idle")
+	m.workers.Add(1, "busy")
+	m.claimed.Inc(j.Queue)
+	if j.Attempts > 0 {
+		m.retries.Inc(j.Queue)
+	}
+	m.queueWait.Observe(queueWait(j, now).Seconds(), j.Queue)
+}
+
+// jobFinished marks the worker idle again and counts the run by the state
+// it left j in; runs whose result was not saved only count as time spent.
+func (m *Metrics) jobFinished(j *job.Job, d time.Duration, saved bool) {
+	if m == nil {
+		return
+	}
+	m.workers.Add(-1, "busy")
+	m.workers.Add(1, "idle")
+	m.duration.Observe(d.Seconds(), j.Queue)
+	if !saved {
+		return
+	}
+	switch j.State {
+	case job.StateCompleted:
+		m.completed.Inc(j.Queue)
+	case job.StateFailed:
+		m.failed.Inc(j.Queue)
+	case job.StateDead:
+		m.dead.Inc(j.Queue)
+	case job.StateCancelled:
+		m.cancelled.Inc(j.Queue)
+	}
+}
+
+// queueWait is how long a claimed job had been runnable: since its retry
+// time, or else since its last change of state, which for a pending job is
+// when it was enqueued, released by its dependencies or retried from the
+// DLQ.
+func queueWait(j *job.Job, now time.Time) time.Duration {
+	ready := j.UpdatedAt
+	if j.RunAt != nil && j.RunAt.After(ready) {
+		ready = *j.RunAt
+	}
+	return max(now.Sub(ready), 0)
+}
@@ -0,0 +1,72 @@
+package queue
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/metrics"
+)
+
+func TestWorkerRecordsMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	w, repo := newTestWorker(t, WorkerConfig{PollInterval: 10 * time.Millisecond, RetryDelay: time.Hour, Metrics: NewMetrics(reg)})
+	for _, j := range []*job.Job{
+		{ID: "ok", Command: "true", Queue: "emails"},
+		{ID: "flaky", Command: "exit 3", MaxRetries: 2},
+	} {
+		if err := repo.Create(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		ok, err := repo.Get("ok")
+		if err != nil {
+			t.Fatal(err)
+		}
+		flaky, err := repo.Get("flaky")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok.State == job.StateCompleted && flaky.State == job.StateFailed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs did not run: ok=%s flaky=%s", ok.State, flaky.State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	var b strings.Builder
+	if err := reg.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, line := range []string{
+		`queuectl_worker_jobs_claimed_total{queue="emails"} 1`,
+		`queuectl_worker_jobs_completed_total{queue="emails"} 1`,
+		`queuectl_worker_jobs_failed_total{queue="default"} 1`,
+		`queuectl_worker_job_duration_seconds_count{queue="default"} 1`,
+		`queuectl_worker_job_queue_wait_seconds_count{queue="emails"} 1`,
+		`queuectl_worker_claim_errors_total 0`,
+		// The worker has stopped, so it is neither busy nor idle.
+		`queuectl_workers{state="busy"} 0`,
+		`queuectl_workers{state="idle"} 0`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing %s in:\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package queue
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Policy decides which job a worker claims next.
+type Policy string
+
+const (
+	// PolicyStrict always takes the highest-priority runnable job, oldest first.
+	PolicyStrict Policy = "strict"
+	// PolicyWeighted rotates between queues in proportion to their weights, so a
+	// busy queue cannot starve the others; within a queue priority still applies.
+	PolicyWeighted Policy = "weighted"
+	// PolicyAging raises a job's effective priority the longer it waits, so
+	// low-priority jobs eventually run even under a steady high-priority load.
+	PolicyAging Policy = "aging"
+)
+
+// ParsePolicy validates a policy name given on the command line.
+func ParsePolicy(name string) (Policy, error) {
+	switch p := Policy(name); p {
+	case PolicyStrict, PolicyWeighted, PolicyAging:
+		return p, nil
+	case "":
+		return PolicyStrict, nil
+	default:
+		return "", fmt.Errorf("unknown scheduling policy %q (want strict, weighted or aging)", name)
+	}
+}
+
+// weightedRoundRobin spreads picks across queues in proportion to their
+// weights using the smooth weighted round-robin algorithm, which interleaves
+// queues (a=2,b=1 gives a,b,a) instead of draining one before the next.
+type weightedRoundRobin struct {
+	queues  []string
+	weights []int
+	current []int
+	total   int
+}
+
+func newWeightedRoundRobin(weights map[string]int) *weightedRoundRobin {
+	wrr := &weightedRoundRobin{}
+	for q := range weights {
+		if weights[q] > 0 {
+			wrr.queues = append(wrr.queues, q)
+		}
+	}
+	sort.Strings(wrr.queues) // deterministic tie-breaking
+	for _, q := range wrr.queues {
+		wrr.weights = append(wrr.weights, weights[q])
+		wrr.total += weights[q]
+	}
+	wrr.current = make([]int, len(wrr.queues))
+	return wrr
+}
+
+// next advances the rotation and returns every queue in the order they should
+// be tried: the queue whose turn it is first, then the rest by weight, so an
+// empty queue hands its turn to the others.
+func (w *weightedRoundRobin) next() []string {
+	if len(w.queues) == 0 {
+		return nil
+	}
+
+	best := 0
+	for i := range w.queues {
+		w.current[i] += w.weights[i]
+		if w.current[i] > w.current[best] {
+			best = i
+		}
+	}
+	w.current[best] -= w.total
+
+	order := make([]int, 0, len(w.queues))
+	for i := range w.queues {
+		if i != best {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return w.weights[order[a]] > w.weights[order[b]] })
+
+	queues := []string{w.queues[best]}
+	for _, i := range order {
+		queues = append(queues, w.queues[i])
+	}
+	return queues
+}
@@ -0,0 +1,30 @@
+package queue
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWeightedRoundRobinInterleavesByWeight(t *testing.T) {
+	wrr := newWeightedRoundRobin(map[string]int{"emails": 3, "reports": 1})
+
+	var picks []string
+	for i := 0; i < 8; i++ {
+		picks = append(picks, wrr.next()[0])
+	}
+
+	got := strings.Join(picks, ",")
+	want := "emails,emails,reports,emails,emails,emails,reports,emails"
+	if got != want {
+		t.Fatalf("unexpected rotation:\n got  %s\n want %s", got, want)
+	}
+}
+
+func TestWeightedRoundRobinFallsBackToOtherQueues(t *testing.T) {
+	wrr := newWeightedRoundRobin(map[string]int{"a": 1, "b": 5, "c": 2})
+
+	order := wrr.next()
+	if len(order) != 3 || order[0] != "b" || order[1] != "c" || order[2] != "a" {
+		t.Fatalf("expected b first then the rest by weight, got %v", order)
+	}
+}
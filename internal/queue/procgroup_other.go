@@ -0,0 +1,9 @@
+//go:build !unix
+
+package queue
+
+import "os/exec"
+
+// setProcessGroup is a no-op where process groups are unavailable; only the
+// direct child is killed on cancellation.
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package queue
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup runs the command in its own process group and makes
+// cancellation kill the whole group, so children spawned by "bash -c" die
+// with it instead of holding the output pipes open.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -1,15 +1,16 @@
 package queue
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"log"
 	"os"
 	"os/exec"
 	"os/signal"
+	"strings"
 	"time"
 
+	"queuectl.backend/internal/job"
 	"queuectl.backend/internal/store"
 )
 
@@ -20,16 +21,39 @@ type WorkerConfig struct {
 	MaxSleepTime time.Duration
 	RetryDelay   time.Duration
 	ExecTimeout  time.Duration
+	// LeaseDuration is how long a claim stays valid without a heartbeat.
+	LeaseDuration time.Duration
+	// HeartbeatInterval is how often a running job's lease is renewed and how
+	// often the worker looks for expired leases to reclaim.
+	HeartbeatInterval time.Duration
+	// Queues lists the queues this worker takes jobs from; empty means all.
+	Queues []string
+	// Policy chooses between runnable jobs (default PolicyStrict).
+	Policy Policy
+	// QueueWeights sets each queue's share of claims under PolicyWeighted.
+	// Subscribed queues without a weight count as 1.
+	QueueWeights map[string]int
+	// AgingStep is the wait that adds one to a job's priority under PolicyAging.
+	AgingStep time.Duration
+	// ExitCodes are the default retry rules for jobs that set none.
+	ExitCodes job.ExitCodeRules
+	// MaxLogSize caps the output stored per stream and attempt (default
+	// DefaultMaxLogSize); the middle of longer output is dropped.
+	MaxLogSize int64
+	// Metrics, when set, records claims, outcomes and timings; workers of
+	// one process share it.
+	Metrics *Metrics
 }
 
 // Worker handles jobs fetched from the repository.
 type Worker struct {
-	repo *store.JobRepo
+	repo store.JobStore
 	cfg  WorkerConfig
+	wrr  *weightedRoundRobin
 }
 
 // NewWorker creates and initializes a new worker with default values.
-func NewWorker(repo *store.JobRepo, cfg WorkerConfig) *Worker {
+func NewWorker(repo store.JobStore, cfg WorkerConfig) *Worker {
 	if cfg.ID == "" {
 		cfg.ID = "worker-" + time.Now().Format("150405")
 	}
@@ -45,18 +69,53 @@ func NewWorker(repo *store.JobRepo, cfg WorkerConfig) *Worker {
 	if cfg.ExecTimeout == 0 {
 		cfg.ExecTimeout = 1 * time.Minute
 	}
-	return &Worker{repo: repo, cfg: cfg}
+	if cfg.MaxLogSize <= 0 {
+		cfg.MaxLogSize = DefaultMaxLogSize
+	}
+	if cfg.LeaseDuration == 0 {
+		cfg.LeaseDuration = 30 * time.Second
+	}
+	if cfg.HeartbeatInterval == 0 || cfg.HeartbeatInterval >= cfg.LeaseDuration {
+		cfg.HeartbeatInterval = cfg.LeaseDuration / 3
+	}
+	if cfg.Policy == "" {
+		cfg.Policy = PolicyStrict
+	}
+	if cfg.AgingStep == 0 {
+		cfg.AgingStep = 1 * time.Minute
+	}
+
+	w := &Worker{repo: repo, cfg: cfg}
+	if cfg.Policy == PolicyWeighted {
+		weights := make(map[string]int)
+		if len(cfg.Queues) == 0 {
+			for q, weight := range cfg.QueueWeights {
+				weights[q] = weight
+			}
+		}
+		for _, q := range cfg.Queues {
+			weights[q] = 1
+			if weight, ok := cfg.QueueWeights[q]; ok {
+				weights[q] = weight
+			}
+		}
+		w.wrr = newWeightedRoundRobin(weights)
+	}
+	return w
 }
 
 // Run starts the worker loop, which runs until Ctrl+C is pressed or context is canceled.
 func (w *Worker) Run(ctx context.Context) error {
 	log.Printf("[%s] started", w.cfg.ID)
+	w.cfg.Metrics.workerStarted()
+	defer w.cfg.Metrics.workerStopped()
 
 	// Handle graceful shutdown
 	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
 	defer stop()
 
 	idleCount := 0 // adaptive backoff counter
+	var lastReap time.Time
 
 	for {
 		select {
@@ -66,10 +125,21 @@ func (w *Worker) Run(ctx context.Context) error {
 		default:
 		}
 
+		// STEP 0: Reclaim jobs whose owning worker stopped heartbeating
+		if time.Since(lastReap) >= w.cfg.HeartbeatInterval {
+			lastReap = time.Now()
+			if n, err := w.repo.ReapExpiredLeases(w.cfg.RetryDelay); err != nil {
+				log.Printf("[%s] reap error: %v", w.cfg.ID, err)
+			} else if n > 0 {
+				log.Printf("[%s] reclaimed %d job(s) with expired leases", w.cfg.ID, n)
+			}
+		}
+
 		// STEP 1: Try to claim a pending job safely
-		j, err := w.repo.PreventRaceCondition(w.cfg.ID)
+		j, err := w.claim()
 		if err != nil {
 			log.Printf("[%s] claim error: %v", w.cfg.ID, err)
+			w.cfg.Metrics.claimFailed()
 			time.Sleep(w.cfg.PollInterval)
 			continue
 		}
@@ -100,18 +170,48 @@ func (w *Worker) Run(ctx context.Context) error {
 
 		// Reset idle count when a job is found
 		idleCount = 0
+		w.cfg.Metrics.jobClaimed(j, time.Now().UTC())
 
-		log.Printf("[%s] processing job %s (%s)", w.cfg.ID, j.ID, j.Command)
+		log.Printf("[%s] processing job %s from queue %s (%s)", w.cfg.ID, j.ID, j.Queue, j.CommandLine())
 
-		// ✅ STEP 3: Execute the command with timeout
-		result := w.ExecCommand(j.Command, w.cfg.ExecTimeout)
+		// ✅ STEP 3: Execute the command with timeout, keeping the lease alive.
+		// Neither is tied to ctx so a job that keeps running through Ctrl+C is
+		// not reclaimed underneath us; the heartbeat kills it if cancelled.
+		timeout := w.cfg.ExecTimeout
+		if j.Timeout > 0 {
+			timeout = time.Duration(j.Timeout)
+		}
+		attempt := &job.Attempt{JobID: j.ID, WorkerID: w.cfg.ID}
+		if err := w.repo.StartAttempt(attempt); err != nil {
+			log.Printf("[%s] error recording attempt for job %s: %v", w.cfg.ID, j.ID, err)
+			attempt = nil
+		}
+		jobCtx, cancelJob := context.WithCancel(context.Background())
+		go w.heartbeat(jobCtx, j.ID, cancelJob)
+		number := 0
+		if attempt != nil {
+			number = attempt.Number
+		}
+		result := w.ExecCommand(jobCtx, j, number, timeout)
+		cancelJob()
+
+		if result.ExitCode >= 0 {
+			code := result.ExitCode
+			j.ExitCode = &code
+		} else {
+			j.ExitCode = nil
+		}
 
-		// ✅ STEP 4: Handle success or failure
-		if result.ExitCode == 0 && result.Err == nil {
-			j.Output = result.Stdout + "\n" + result.Stderr
-			j.Duration = result.Duration.Seconds()
+		j.Output = result.Stdout + "\n" + result.Stderr
+		j.Duration = result.Duration.Seconds()
 
-			if err := w.repo.MarkCompleted(j); err != nil {
+		// ✅ STEP 4: Handle success, cancellation or failure
+		if errors.Is(result.Err, ErrJobCancelled) {
+			err = w.markCancelled(j)
+		} else if result.ExitCode == 0 && result.Err == nil {
+			if err = w.repo.MarkCompleted(j); errors.Is(err, store.ErrLeaseLost) {
+				log.Printf("[%s] job %s finished after its lease was reclaimed; result discarded", w.cfg.ID, j.ID)
+			} else if err != nil {
 				log.Printf("[%s] error marking job complete: %v", w.cfg.ID, err)
 			} else {
 				log.Printf("[%s] job %s completed successfully in %.2fs", w.cfg.ID, j.ID, j.Duration)
@@ -121,46 +221,199 @@ func (w *Worker) Run(ctx context.Context) error {
 			if errMsg == "" && result.Err != nil {
 				errMsg = result.Err.Error()
 			}
-			if err := w.repo.Failed(j, errMsg, w.cfg.RetryDelay); err != nil {
+			retry := j.ShouldRetry(result.ExitCode, w.cfg.ExitCodes)
+			if retry {
+				err = w.repo.Failed(j, errMsg, w.cfg.RetryDelay)
+			} else {
+				err = w.repo.MarkDead(j, errMsg)
+			}
+			if errors.Is(err, store.ErrLeaseLost) {
+				log.Printf("[%s] job %s failed after its lease was reclaimed; result discarded", w.cfg.ID, j.ID)
+			} else if err != nil {
 				log.Printf("[%s] error marking job failed: %v", w.cfg.ID, err)
+			} else if j.State == job.StateCancelled {
+				log.Printf("[%s] job %s failed after it was asked to cancel; marked cancelled", w.cfg.ID, j.ID)
+			} else if !retry {
+				log.Printf("[%s] job %s failed with exit code %d, not retryable; moved to DLQ: %s", w.cfg.ID, j.ID, result.ExitCode, errMsg)
 			} else {
-				log.Printf("[%s] job %s failed (retry or DLQ): %s", w.cfg.ID, j.ID, errMsg)
+				log.Printf("[%s] job %s failed with exit code %d (retry or DLQ): %s", w.cfg.ID, j.ID, result.ExitCode, errMsg)
+			}
+		}
+
+		w.cfg.Metrics.jobFinished(j, result.Duration, err == nil)
+		if attempt != nil {
+			w.finishAttempt(attempt, j, result, err)
+		}
+	}
+}
+
+// markCancelled records that j was killed on request. Losing the lease
+// meanwhile is expected, as the job is going away anyway, and not an error.
+func (w *Worker) markCancelled(j *job.Job) error {
+	err := w.repo.MarkCancelled(j)
+	if errors.Is(err, store.ErrLeaseLost) {
+		log.Printf("[%s] job %s cancelled after its lease was reclaimed; nothing to record", w.cfg.ID, j.ID)
+	} else if err != nil {
+		log.Printf("[%s] error marking job cancelled: %v", w.cfg.ID, err)
+	} else {
+		log.Printf("[%s] job %s cancelled after %.2fs", w.cfg.ID, j.ID, j.Duration)
+	}
+	return err
+}
+
+// claim takes the next job according to the worker's scheduling policy.
+func (w *Worker) claim() (*job.Job, error) {
+	opts := store.ClaimOptions{
+		WorkerID: w.cfg.ID,
+		Lease:    w.cfg.LeaseDuration,
+		Queues:   w.cfg.Queues,
+	}
+
+	switch w.cfg.Policy {
+	case PolicyAging:
+		opts.AgingStep = w.cfg.AgingStep
+	case PolicyWeighted:
+		for _, q := range w.wrr.next() {
+			opts.Queues = []string{q}
+			j, err := w.repo.PreventRaceCondition(opts)
+			if err != nil || j != nil {
+				return j, err
 			}
 		}
+		// Weighted queues are empty; fall back to anything else we may take.
+		opts.Queues = w.cfg.Queues
+	}
+	return w.repo.PreventRaceCondition(opts)
+}
+
+// finishAttempt records the result of one run of j in its attempt history.
+// finishErr is the error from saving the job's new state, if any.
+func (w *Worker) finishAttempt(a *job.Attempt, j *job.Job, result ExecResult, finishErr error) {
+	a.ExitCode = j.ExitCode
+	a.Stdout = result.Stdout
+	a.Stderr = result.Stderr
+	if result.Err != nil {
+		msg := result.Err.Error()
+		a.Error = &msg
+	}
+	switch {
+	case errors.Is(finishErr, store.ErrLeaseLost):
+		msg := "lease lost before the result was saved; result discarded"
+		a.Error = &msg
+	case finishErr == nil:
+		a.State = j.State
+	}
+	if err := w.repo.FinishAttempt(a); err != nil {
+		log.Printf("[%s] error recording attempt for job %s: %v", w.cfg.ID, j.ID, err)
 	}
 }
 
-// ✅ Timeout-aware command executor
-func (w *Worker) ExecCommand(command string, timeout time.Duration) ExecResult {
+// heartbeat renews the lease on jobID until ctx is canceled or the lease is
+// lost, and calls cancelJob once the job's cancellation is requested.
+func (w *Worker) heartbeat(ctx context.Context, jobID string, cancelJob context.CancelFunc) {
+	ticker := time.NewTicker(w.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cancelRequested, err := w.repo.Heartbeat(jobID, w.cfg.ID, w.cfg.LeaseDuration)
+			if errors.Is(err, store.ErrLeaseLost) {
+				log.Printf("[%s] lost lease on job %s", w.cfg.ID, jobID)
+				return
+			}
+			if err != nil {
+				log.Printf("[%s] heartbeat error for job %s: %v", w.cfg.ID, jobID, err)
+				continue
+			}
+			if cancelRequested {
+				log.Printf("[%s] cancellation requested for job %s, killing it", w.cfg.ID, jobID)
+				cancelJob()
+				return
+			}
+		}
+	}
+}
+
+// ✅ Timeout-aware command executor. Runs j's argv (through bash or
+// directly, see job.ExecShell) with its environment, working directory and
+// stdin, streaming its output into the job_logs of the given attempt.
+// Canceling ctx kills the command's whole process group and reports
+// ErrJobCancelled.
+func (w *Worker) ExecCommand(ctx context.Context, j *job.Job, attempt int, timeout time.Duration) ExecResult {
 	start := time.Now()
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "bash", "-c", command)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	argv := j.Argv()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = j.Cwd
+	if len(j.Env) > 0 {
+		cmd.Env = os.Environ()
+		for name, value := range j.Env {
+			cmd.Env = append(cmd.Env, name+"="+value)
+		}
+	}
+	if j.Stdin != "" {
+		cmd.Stdin = strings.NewReader(j.Stdin)
+	}
+	setProcessGroup(cmd)
+	// Don't wait forever on pipes held open by processes that escaped the group.
+	cmd.WaitDelay = 5 * time.Second
+	stdout := newLogWriter(w.repo, j.ID, attempt, job.StreamStdout, w.cfg.MaxLogSize)
+	stderr := newLogWriter(w.repo, j.ID, attempt, job.StreamStderr, w.cfg.MaxLogSize)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	synced := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(logFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-synced:
+				return
+			case <-ticker.C:
+				stdout.Sync()
+				stderr.Sync()
+			}
+		}
+	}()
 
 	err := cmd.Run()
 	duration := time.Since(start)
+	close(synced)
+	for _, lw := range []*logWriter{stdout, stderr} {
+		if err := lw.Close(); err != nil {
+			log.Printf("[%s] error saving output of job %s: %v", w.cfg.ID, j.ID, err)
+		}
+	}
 
 	result := ExecResult{
-		Stdout:   stdout.String(),
-		Stderr:   stderr.String(),
+		Stdout:   stdout.Preview(),
+		Stderr:   stderr.Preview(),
 		Err:      err,
 		Duration: duration,
 	}
 
-	// Determine exit code
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		result.ExitCode = exitErr.ExitCode()
-	} else if err == nil {
+	// Determine exit code. A killed command also reports an ExitError, so
+	// look at why the context ended before trusting the exit status.
+	if err == nil {
 		result.ExitCode = 0
 	} else if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 		result.Err = errors.New("job timeout exceeded")
 		result.ExitCode = -1
 		log.Printf("[%s] job timed out after %v", w.cfg.ID, timeout)
+	} else if errors.Is(ctx.Err(), context.Canceled) {
+		result.Err = ErrJobCancelled
+		result.ExitCode = -1
+	} else if exitErr, ok := err.(*exec.ExitError); ok {
+		result.ExitCode = exitErr.ExitCode()
+	} else {
+		result.ExitCode = 1
 	}
 
 	return result
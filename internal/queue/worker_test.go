@@ -0,0 +1,40 @@
+package queue
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/store"
+)
+
+func TestCancelledJobWithLostLeaseIsNotAnError(t *testing.T) {
+	w, repo := newTestWorker(t, WorkerConfig{})
+	if err := repo.Create(&job.Job{ID: "a", Command: "sleep 60"}); err != nil {
+		t.Fatal(err)
+	}
+	j, err := repo.PreventRaceCondition(store.ClaimOptions{WorkerID: "test", Lease: time.Minute})
+	if err != nil || j == nil {
+		t.Fatalf("claim failed: %v %+v", err, j)
+	}
+	// The lease ran out while the job was being killed and another worker
+	// took it over.
+	if err := repo.DB().Model(&job.Job{}).Where("id = ?", "a").Update("worker_id", "other").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	if err := w.markCancelled(j); !errors.Is(err, store.ErrLeaseLost) {
+		t.Fatalf("markCancelled = %v, want ErrLeaseLost", err)
+	}
+	if out := logged.String(); strings.Contains(out, "error") || !strings.Contains(out, "cancelled after its lease was reclaimed") {
+		t.Fatalf("unexpected log: %q", out)
+	}
+}
@@ -0,0 +1,193 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+	"queuectl.backend/internal/job"
+)
+
+// ErrNotFound is returned when no schedule has the requested ID.
+var ErrNotFound = errors.New("schedule not found")
+
+// Schedule is a recurring job definition. Each time NextRunAt passes, the
+// scheduler materializes one job from the Job template and moves NextRunAt to
+// the following cron tick.
+type Schedule struct {
+	ID       string `json:"id" gorm:"primaryKey;size:40"`
+	Cron     string `json:"cron" gorm:"not null"`
+	Timezone string `json:"timezone" gorm:"not null;default:'UTC'"`
+	// Job is the enqueue JSON used as the template for every run.
+	Job       string     `json:"job" gorm:"not null"`
+	Paused    bool       `json:"paused" gorm:"not null;default:false;index"`
+	NextRunAt time.Time  `json:"next_run_at" gorm:"index"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	LastJobID *string    `json:"last_job_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Next returns the first cron tick strictly after t, evaluated in the
+// schedule's timezone.
+func (s *Schedule) Next(t time.Time) (time.Time, error) {
+	spec, err := cron.ParseStandard(s.Cron)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cron expression %q: %w", s.Cron, err)
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timezone %q: %w", s.Timezone, err)
+	}
+	return spec.Next(t.In(loc)).UTC(), nil
+}
+
+// NewJob builds the job for the run due at tick. The ID is derived from the
+// schedule and the tick, so a run can never be inserted twice.
+func (s *Schedule) NewJob(tick time.Time) (*job.Job, error) {
+	var j job.Job
+	if err := json.Unmarshal([]byte(s.Job), &j); err != nil {
+		return nil, fmt.Errorf("invalid job template: %w", err)
+	}
+	if err := j.Normalize(); err != nil {
+		return nil, fmt.Errorf("invalid job template: %w", err)
+	}
+	j.ID = fmt.Sprintf("%s-%d", s.ID, tick.Unix())
+	return &j, nil
+}
+
+// JobStore is what the schedules need from the job store: its database and
+// its job insert, so fired jobs get the same checks as enqueued ones.
+type JobStore interface {
+	DB() *gorm.DB
+	CreateInTx(tx *gorm.DB, j *job.Job) error
+}
+
+// Repository wraps access to the schedules table.
+type Repository struct {
+	db   *gorm.DB
+	jobs JobStore
+}
+
+func NewRepository(jobs JobStore) *Repository {
+	return &Repository{db: jobs.DB(), jobs: jobs}
+}
+
+// Add validates a schedule, computes its first run and stores it.
+func (r *Repository) Add(s *Schedule) error {
+	if s.Timezone == "" {
+		s.Timezone = "UTC"
+	}
+	if _, err := s.NewJob(time.Now()); err != nil {
+		return err
+	}
+	next, err := s.Next(time.Now())
+	if err != nil {
+		return err
+	}
+	s.NextRunAt = next
+	return r.db.Create(s).Error
+}
+
+func (r *Repository) Get(id string) (*Schedule, error) {
+	var s Schedule
+	if err := r.db.Where("id = ?", id).Limit(1).Find(&s).Error; err != nil {
+		return nil, err
+	}
+	if s.ID == "" {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+func (r *Repository) All() ([]Schedule, error) {
+	var items []Schedule
+	if err := r.db.Order("id").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// SetPaused pauses or resumes a schedule. Resuming starts again from the next
+// tick after now instead of catching up on the runs missed while paused.
+func (r *Repository) SetPaused(id string, paused bool) error {
+	s, err := r.Get(id)
+	if err != nil {
+		return err
+	}
+	updates := map[string]interface{}{"paused": paused}
+	if !paused {
+		next, err := s.Next(time.Now())
+		if err != nil {
+			return err
+		}
+		updates["next_run_at"] = next
+	}
+	return r.db.Model(s).Updates(updates).Error
+}
+
+func (r *Repository) Delete(id string) error {
+	res := r.db.Delete(&Schedule{}, "id = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Due returns active schedules whose next run is at or before now.
+func (r *Repository) Due(now time.Time) ([]Schedule, error) {
+	var items []Schedule
+	err := r.db.Where("paused = ? AND next_run_at <= ?", false, now).Order("next_run_at").Find(&items).Error
+	return items, err
+}
+
+// Fire materializes the run of s that is due and advances it to the next tick
+// after now, in one transaction. The advance is conditional on NextRunAt
+// still holding the value s was loaded with, so when several schedulers race
+// for the same tick exactly one of them creates the job. Runs missed while no
+// scheduler was running are collapsed into this single run. If the job cannot
+// be created, for instance because an earlier run still holds its
+// idempotency key, the tick stays due and is tried again.
+func (r *Repository) Fire(s *Schedule, now time.Time) (*job.Job, error) {
+	tick := s.NextRunAt
+	j, err := s.NewJob(tick)
+	if err != nil {
+		return nil, err
+	}
+	next, err := s.Next(now)
+	if err != nil {
+		return nil, err
+	}
+
+	var fired bool
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Schedule{}).
+			Where("id = ? AND paused = ? AND next_run_at = ?", s.ID, false, tick).
+			Updates(map[string]interface{}{
+				"next_run_at": next,
+				"last_run_at": tick,
+				"last_job_id": j.ID,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil // another scheduler took this tick
+		}
+		fired = true
+		return r.jobs.CreateInTx(tx, j)
+	})
+	if err != nil || !fired {
+		return nil, err
+	}
+	s.NextRunAt = next
+	s.LastRunAt = &tick
+	s.LastJobID = &j.ID
+	return j, nil
+}
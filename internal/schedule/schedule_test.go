@@ -0,0 +1,134 @@
+package schedule_test
+
+import (
+	"testing"
+	"time"
+
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/schedule"
+	"queuectl.backend/internal/store"
+)
+
+func TestConcurrentSchedulersFireOncePerTick(t *testing.T) {
+	jobs, err := store.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { jobs.Close() })
+	repo := schedule.NewRepository(jobs)
+
+	s := &schedule.Schedule{ID: "every-minute", Cron: "* * * * *", Job: `{"command":"echo tick","queue":"cron"}`}
+	if err := repo.Add(s); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two schedulers load the same due schedule and both try to fire it.
+	now := s.NextRunAt.Add(time.Second)
+	first, _ := repo.Get(s.ID)
+	second, _ := repo.Get(s.ID)
+
+	j1, err := repo.Fire(first, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j2, err := repo.Fire(second, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j1 == nil || j2 != nil {
+		t.Fatalf("expected exactly one job, got %v and %v", j1, j2)
+	}
+
+	created, err := jobs.Get(j1.ID)
+	if err != nil {
+		t.Fatalf("fired job not stored: %v", err)
+	}
+	if created.Queue != "cron" || created.Command != "echo tick" {
+		t.Fatalf("job not built from template: %+v", created)
+	}
+
+	stored, _ := repo.Get(s.ID)
+	if !stored.NextRunAt.After(now) {
+		t.Fatalf("next run %v not advanced past %v", stored.NextRunAt, now)
+	}
+}
+
+func TestNextHonorsTimezone(t *testing.T) {
+	s := &schedule.Schedule{Cron: "0 2 * * *", Timezone: "America/New_York"}
+
+	from := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	next, err := s.Next(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 02:00 in New York (EST, UTC-5) is 07:00 UTC.
+	if want := time.Date(2026, 1, 16, 7, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Fatalf("expected %v, got %v", want, next)
+	}
+}
+
+func TestFireCreatesJobsLikeEnqueue(t *testing.T) {
+	jobs, err := store.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { jobs.Close() })
+	repo := schedule.NewRepository(jobs)
+	if err := jobs.Create(&job.Job{ID: "setup", Command: "true"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &schedule.Schedule{ID: "report", Cron: "* * * * *",
+		Job: `{"command":"./report.sh","depends_on":["setup"],"idempotency_key":"report","unique_scope":"unfinished"}`}
+	if err := repo.Add(s); err != nil {
+		t.Fatal(err)
+	}
+	now := s.NextRunAt.Add(time.Second)
+	j, err := repo.Fire(s, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := jobs.Get(j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.State != job.StateBlocked {
+		t.Fatalf("job with an unfinished dependency is %s, want blocked", created.State)
+	}
+	var edges int64
+	jobs.DB().Model(&job.Dependency{}).Where("job_id = ? AND parent_id = ?", j.ID, "setup").Count(&edges)
+	if edges != 1 {
+		t.Fatalf("got %d dependency edges, want 1", edges)
+	}
+
+	// The first run still holds the key, so the next tick is not enqueued
+	// and stays due.
+	s.NextRunAt = now.Add(-time.Second)
+	jobs.DB().Model(s).Update("next_run_at", s.NextRunAt)
+	tick := s.NextRunAt
+	if j, err := repo.Fire(s, now); err == nil {
+		t.Fatalf("fired %v while the key was held", j)
+	}
+	stored, _ := repo.Get(s.ID)
+	if !stored.NextRunAt.Equal(tick) {
+		t.Fatalf("next run moved to %v although the run was not created", stored.NextRunAt)
+	}
+}
+
+func TestNewJobClearsRunFields(t *testing.T) {
+	s := &schedule.Schedule{ID: "tidy", Job: `{"command":"./tidy.sh","state":"pending","attempts":3,` +
+		`"worker_id":"w1","exit_code":1,"lease_expires_at":"2030-01-01T00:00:00Z","cancel_requested":true}`}
+	j, err := s.NewJob(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.State != job.StatePending || j.Attempts != 0 || j.WorkerID != nil || j.ExitCode != nil ||
+		j.LeaseExpiresAt != nil || j.CancelRequested {
+		t.Fatalf("template run fields kept: %+v", j)
+	}
+
+	s.Job = `{"command":"./tidy.sh","state":"completed"}`
+	if _, err := s.NewJob(time.Now()); err == nil {
+		t.Fatal("accepted a template with a completed state")
+	}
+}
@@ -0,0 +1,51 @@
+package schedule
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Run fires due schedules every interval until ctx is canceled. Any number of
+// schedulers may run against the same database; Fire guarantees each tick
+// produces at most one job.
+func Run(ctx context.Context, repo *Repository, interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	log.Printf("[scheduler] started (checking every %v)", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		tick(repo)
+
+		select {
+		case <-ctx.Done():
+			log.Printf("[scheduler] stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func tick(repo *Repository) {
+	now := time.Now().UTC()
+	due, err := repo.Due(now)
+	if err != nil {
+		log.Printf("[scheduler] failed to load due schedules: %v", err)
+		return
+	}
+	for i := range due {
+		s := &due[i]
+		j, err := repo.Fire(s, now)
+		if err != nil {
+			log.Printf("[scheduler] schedule %s failed to fire: %v", s.ID, err)
+			continue
+		}
+		if j != nil {
+			log.Printf("[scheduler] schedule %s enqueued job %s (next run %s)", s.ID, j.ID, s.NextRunAt.Format(time.RFC3339))
+		}
+	}
+}
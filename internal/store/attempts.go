@@ -0,0 +1,57 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"queuectl.backend/internal/job"
+)
+
+// StartAttempt records that a worker began running a job, numbering the
+// attempt after any earlier ones for the same job.
+func (r *JobRepo) StartAttempt(a *job.Attempt) error {
+	if a == nil {
+		return errors.New("attempt cannot be nil")
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&job.Attempt{}).Where("job_id = ?", a.JobID).Count(&n).Error; err != nil {
+			return err
+		}
+		a.Number = int(n) + 1
+		if a.StartedAt.IsZero() {
+			a.StartedAt = time.Now().UTC()
+		}
+		return tx.Create(a).Error
+	})
+}
+
+// FinishAttempt stores the result of an attempt opened by StartAttempt.
+func (r *JobRepo) FinishAttempt(a *job.Attempt) error {
+	if a == nil || a.ID == 0 {
+		return errors.New("attempt was never started")
+	}
+	if a.FinishedAt == nil {
+		now := time.Now().UTC()
+		a.FinishedAt = &now
+	}
+	return r.db.Model(a).
+		Select("finished_at", "exit_code", "state", "stdout", "stderr", "error").
+		Updates(a).Error
+}
+
+// ListAttempts returns a job's attempts, oldest first.
+func (r *JobRepo) ListAttempts(jobID string) ([]job.Attempt, error) {
+	var attempts []job.Attempt
+	err := r.db.Where("job_id = ?", jobID).Order("number ASC").Find(&attempts).Error
+	return attempts, err
+}
+
+// closeOpenAttempts ends the attempts of a job whose worker vanished, so the
+// history does not show them running forever.
+func closeOpenAttempts(tx *gorm.DB, jobID string, state job.JobState, msg string, now time.Time) error {
+	return tx.Model(&job.Attempt{}).
+		Where("job_id = ? AND finished_at IS NULL", jobID).
+		Updates(map[string]any{"finished_at": now, "state": state, "error": msg}).Error
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"queuectl.backend/internal/job"
+)
+
+// batchSize is the number of rows per INSERT in CreateBatch (and per IN list
+// when looking up existing IDs), well under SQLite's bound-variable limit.
+const batchSize = 200
+
+// BatchError reports why one job of a CreateBatch call was not inserted.
+type BatchError struct {
+	// Index is the job's position in the slice passed to CreateBatch.
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("job #%d: %v", e.Index+1, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// CreateBatch inserts many jobs in a single transaction. Plain jobs are
+// written with CreateInBatches, or one by one if another client took one of
+// their IDs meanwhile; jobs with dependencies (on each other or on stored
+// jobs) or an idempotency key follow one by one in dependency order.
+//
+// With atomic set, any rejected job leaves the store untouched. Otherwise the
+// rejected jobs, and jobs depending on them, are skipped and the rest are
+// inserted. Either way the rejected jobs are returned; err is only set when
+// the database itself fails, in which case nothing was inserted. Jobs skipped
+// as duplicates of an idempotency key are returned with a *DuplicateError
+// and never make an atomic batch fail.
+func (r *JobRepo) CreateBatch(jobs []*job.Job, atomic bool) ([]BatchError, error) {
+	now := time.Now().UTC()
+	index := make(map[*job.Job]int, len(jobs))
+	seen := make(map[string]bool, len(jobs))
+	var rejected []BatchError
+	reject := func(i int, err error) {
+		rejected = append(rejected, BatchError{Index: i, Err: err})
+	}
+
+	var candidates []*job.Job
+	for i, j := range jobs {
+		index[j] = i
+		switch {
+		case j.ID == "":
+			reject(i, fmt.Errorf("job has no id"))
+		case seen[j.ID]:
+			reject(i, fmt.Errorf("duplicate job id %s in batch", j.ID))
+		default:
+			seen[j.ID] = true
+			if err := validateJob(j); err != nil {
+				reject(i, err)
+				continue
+			}
+			candidates = append(candidates, j)
+		}
+	}
+
+	existing, err := r.existingIDs(candidates)
+	if err != nil {
+		return nil, err
+	}
+	var plain, dependent []*job.Job
+	for _, j := range candidates {
+		if existing[j.ID] {
+			reject(index[j], jobExistsError(j.ID))
+			continue
+		}
+		if j.Queue == "" {
+			j.Queue = job.DefaultQueue
+		}
+		if j.State == "" {
+			j.State = job.StatePending
+		}
+		j.CreatedAt = now
+		j.UpdatedAt = now
+		if len(j.DependsOn) == 0 && j.IdempotencyKey == "" {
+			plain = append(plain, j)
+		} else {
+			dependent = append(dependent, j)
+		}
+	}
+	if atomic && len(rejected) > 0 {
+		return sortRejected(rejected), nil
+	}
+
+	ordered, err := topoSort(dependent)
+	if err != nil {
+		sorted := make(map[*job.Job]bool, len(ordered))
+		for _, j := range ordered {
+			sorted[j] = true
+		}
+		for _, j := range dependent {
+			if !sorted[j] {
+				reject(index[j], fmt.Errorf("job %s is in, or depends on, a dependency cycle", j.ID))
+			}
+		}
+		if atomic {
+			return sortRejected(rejected), nil
+		}
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if len(plain) > 0 {
+			err := tx.Transaction(func(sp *gorm.DB) error {
+				return sp.CreateInBatches(plain, batchSize).Error
+			})
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				// An ID was taken after existingIDs looked; insert one by one
+				// to find out which.
+				err = insertEach(tx, plain, atomic, func(j *job.Job) { reject(index[j], jobExistsError(j.ID)) })
+			}
+			if err != nil {
+				return err
+			}
+		}
+		for _, j := range ordered {
+			var err error
+			if atomic {
+				err = createJob(tx, j)
+			} else {
+				// A savepoint lets one bad job fail without the rest.
+				err = tx.Transaction(func(sp *gorm.DB) error { return createJob(sp, j) })
+			}
+			if err != nil {
+				reject(index[j], err)
+				var dup *DuplicateError
+				if atomic && !errors.As(err, &dup) {
+					return errBatchRejected
+				}
+			}
+		}
+		return nil
+	})
+	if err == errBatchRejected {
+		err = nil
+	}
+	return sortRejected(rejected), err
+}
+
+// insertEach inserts jobs one per savepoint, passing those whose ID is taken
+// to taken. With atomic set the first taken ID rolls the batch back.
+func insertEach(tx *gorm.DB, jobs []*job.Job, atomic bool, taken func(*job.Job)) error {
+	for _, j := range jobs {
+		err := tx.Transaction(func(sp *gorm.DB) error { return sp.Create(j).Error })
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			taken(j)
+			if atomic {
+				return errBatchRejected
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortRejected(rejected []BatchError) []BatchError {
+	slices.SortFunc(rejected, func(a, b BatchError) int { return a.Index - b.Index })
+	return rejected
+}
+
+// errBatchRejected rolls back an atomic batch after a job was rejected.
+var errBatchRejected = errors.New("batch rejected")
+
+// existingIDs returns which of the jobs' IDs are already taken, including by
+// soft-deleted jobs.
+func (r *JobRepo) existingIDs(jobs []*job.Job) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	for start := 0; start < len(jobs); start += batchSize {
+		end := min(start+batchSize, len(jobs))
+		ids := make([]string, 0, end-start)
+		for _, j := range jobs[start:end] {
+			ids = append(ids, j.ID)
+		}
+		var found []string
+		if err := r.db.Unscoped().Model(&job.Job{}).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range found {
+			existing[id] = true
+		}
+	}
+	return existing, nil
+}
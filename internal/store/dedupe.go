@@ -0,0 +1,63 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"queuectl.backend/internal/job"
+)
+
+// ErrJobExists is returned when a job is created with an ID that is taken.
+var ErrJobExists = errors.New("already exists")
+
+func jobExistsError(id string) error {
+	return fmt.Errorf("job %s %w", id, ErrJobExists)
+}
+
+// DuplicateError is returned instead of inserting a job whose idempotency key
+// is still held by an earlier job.
+type DuplicateError struct {
+	Key        string
+	ExistingID string
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("job %s already holds idempotency key %q", e.ExistingID, e.Key)
+}
+
+// createJob inserts j inside tx after making sure its ID is free and its
+// idempotency key is not held by another job. A job whose scope has run out
+// gives its key up here, when the next job with that key arrives.
+func createJob(tx *gorm.DB, j *job.Job) error {
+	if err := j.NormalizeUnique(); err != nil {
+		return errorf(ErrInvalidJob, "job %s: %v", j.ID, err)
+	}
+
+	var taken int64
+	if err := tx.Unscoped().Model(&job.Job{}).Where("id = ?", j.ID).Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return jobExistsError(j.ID)
+	}
+
+	if key := j.IdempotencyKey; key != "" {
+		var holder job.Job
+		if err := tx.Unscoped().Where("dedupe_key = ?", key).Limit(1).Find(&holder).Error; err != nil {
+			return err
+		}
+		if holder.ID != "" {
+			if !holder.DeletedAt.Valid && holder.HoldsUniqueKey(time.Now().UTC()) {
+				return &DuplicateError{Key: key, ExistingID: holder.ID}
+			}
+			if err := tx.Unscoped().Model(&holder).UpdateColumn("dedupe_key", nil).Error; err != nil {
+				return err
+			}
+		}
+		j.DedupeKey = &key
+	}
+
+	return insertJob(tx, j)
+}
@@ -0,0 +1,253 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"queuectl.backend/internal/job"
+)
+
+// insertJob creates j inside tx together with its dependency edges. A job
+// with dependencies starts blocked unless they have all completed already.
+func insertJob(tx *gorm.DB, j *job.Job) error {
+	if err := validateJob(j); err != nil {
+		return errorf(ErrInvalidJob, "%v", err)
+	}
+	if len(j.DependsOn) > 0 {
+		if j.State == "" || j.State == job.StateBlocked {
+			j.State = job.StatePending
+		}
+		if err := lockParents(tx, j.DependsOn); err != nil {
+			return err
+		}
+		if err := resolveDependencies(tx, j); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Create(j).Error; err != nil {
+		return err
+	}
+	if len(j.DependsOn) == 0 {
+		return nil
+	}
+
+	edges := make([]job.Dependency, 0, len(j.DependsOn))
+	for _, parent := range j.DependsOn {
+		edges = append(edges, job.Dependency{JobID: j.ID, ParentID: parent})
+	}
+	return tx.Create(&edges).Error
+}
+
+// lockParents share-locks the parent rows until tx ends, so none of them can
+// finish (and release its dependents) between a caller reading their states
+// and writing the child, which would leave the child blocked for good.
+// SQLite runs one writer at a time and ignores the clause.
+func lockParents(tx *gorm.DB, parents []string) error {
+	var locked []job.Job
+	return tx.Clauses(clause.Locking{Strength: "SHARE"}).Select("id").
+		Where("id IN ?", parents).Order("id").Find(&locked).Error
+}
+
+// validateJob checks the fields of j that the database cannot, and
+// deduplicates its dependencies.
+func validateJob(j *job.Job) error {
+	switch j.OnParentDead {
+	case "", job.OnParentDeadCascade, job.OnParentDeadSkip:
+	default:
+		return fmt.Errorf("invalid on_parent_dead %q (want %s or %s)", j.OnParentDead, job.OnParentDeadCascade, job.OnParentDeadSkip)
+	}
+	if err := j.ValidatePayload(); err != nil {
+		return fmt.Errorf("job %s: %w", j.ID, err)
+	}
+	if j.Timeout < 0 {
+		return fmt.Errorf("job %s has a negative timeout", j.ID)
+	}
+	if err := j.Backoff.Validate(); err != nil {
+		return fmt.Errorf("job %s: %w", j.ID, err)
+	}
+	j.DependsOn = uniqueStrings(j.DependsOn)
+	for _, parent := range j.DependsOn {
+		if parent == j.ID {
+			return fmt.Errorf("job %s cannot depend on itself", j.ID)
+		}
+	}
+	return nil
+}
+
+// resolveDependencies sets the state of a job that has dependencies:
+// blocked while any of them is unfinished, dead if one is dead and the job
+// cascades failures, otherwise pending. It leaves other states alone.
+func resolveDependencies(tx *gorm.DB, j *job.Job) error {
+	var parents []job.Job
+	if err := tx.Select("id", "state").Where("id IN ?", j.DependsOn).Find(&parents).Error; err != nil {
+		return err
+	}
+	if len(parents) != len(j.DependsOn) {
+		found := make(map[string]bool, len(parents))
+		for _, p := range parents {
+			found[p.ID] = true
+		}
+		var missing []string
+		for _, id := range j.DependsOn {
+			if !found[id] {
+				missing = append(missing, id)
+			}
+		}
+		return errorf(ErrInvalidJob, "job %s depends on unknown job(s): %v", j.ID, missing)
+	}
+
+	waiting := false
+	for _, p := range parents {
+		switch p.State {
+		case job.StateCompleted:
+		case job.StateDead, job.StateCancelled:
+			if j.OnParentDead == job.OnParentDeadSkip {
+				continue
+			}
+			msg := fmt.Sprintf("dependency %s is %s", p.ID, p.State)
+			j.State = job.StateDead
+			j.LastError = &msg
+			return nil
+		default:
+			waiting = true
+		}
+	}
+
+	if waiting {
+		j.State = job.StateBlocked
+	} else if j.State == job.StateBlocked {
+		j.State = job.StatePending
+	}
+	return nil
+}
+
+// releaseDependents re-evaluates the blocked jobs waiting on parentID after it
+// completed, died or was cancelled. Jobs that die because of it cascade to their own
+// dependents in turn.
+func releaseDependents(tx *gorm.DB, parentID string) error {
+	pending := []string{parentID}
+	for len(pending) > 0 {
+		id := pending[0]
+		pending = pending[1:]
+
+		var children []job.Job
+		if err := tx.
+			Where("state = ? AND id IN (?)", job.StateBlocked,
+				tx.Model(&job.Dependency{}).Select("job_id").Where("parent_id = ?", id)).
+			Find(&children).Error; err != nil {
+			return err
+		}
+
+		for i := range children {
+			child := &children[i]
+			if err := resolveDependencies(tx, child); err != nil {
+				return err
+			}
+			if child.State == job.StateBlocked {
+				continue
+			}
+			child.UpdatedAt = time.Now().UTC()
+			if err := tx.Model(child).Select("state", "last_error", "updated_at").Updates(child).Error; err != nil {
+				return err
+			}
+			if child.State == job.StateDead {
+				pending = append(pending, child.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// CreateWorkflow inserts a set of jobs that may depend on each other, and on
+// jobs already in the store, in a single transaction: either the whole DAG is
+// enqueued or nothing is. Cycles and unknown dependencies are rejected.
+func (r *JobRepo) CreateWorkflow(jobs []*job.Job) error {
+	ordered, err := topoSort(jobs)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, j := range ordered {
+			if j.Queue == "" {
+				j.Queue = job.DefaultQueue
+			}
+			j.CreatedAt = now
+			j.UpdatedAt = now
+			if err := createJob(tx, j); err != nil {
+				return fmt.Errorf("job %s: %w", j.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// topoSort orders jobs so every job comes after the jobs it depends on within
+// the set. Dependencies outside the set are left for insertJob to check. On a
+// cycle it returns the jobs it could order along with the error.
+func topoSort(jobs []*job.Job) ([]*job.Job, error) {
+	byID := make(map[string]*job.Job, len(jobs))
+	for _, j := range jobs {
+		if j.ID == "" {
+			return nil, fmt.Errorf("every workflow job needs an id")
+		}
+		if byID[j.ID] != nil {
+			return nil, fmt.Errorf("duplicate job id %s in workflow", j.ID)
+		}
+		byID[j.ID] = j
+	}
+
+	indegree := make(map[string]int, len(jobs))
+	children := make(map[string][]string)
+	for _, j := range jobs {
+		for _, parent := range uniqueStrings(j.DependsOn) {
+			if byID[parent] != nil {
+				indegree[j.ID]++
+				children[parent] = append(children[parent], j.ID)
+			}
+		}
+	}
+
+	var ready []string
+	for _, j := range jobs {
+		if indegree[j.ID] == 0 {
+			ready = append(ready, j.ID)
+		}
+	}
+
+	ordered := make([]*job.Job, 0, len(jobs))
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, byID[id])
+		for _, child := range children[id] {
+			indegree[child]--
+			if indegree[child] == 0 {
+				ready = append(ready, child)
+			}
+		}
+	}
+	if len(ordered) != len(jobs) {
+		return ordered, fmt.Errorf("workflow contains a dependency cycle")
+	}
+	return ordered, nil
+}
+
+func uniqueStrings(values []string) []string {
+	if len(values) == 0 {
+		return values
+	}
+	seen := make(map[string]bool, len(values))
+	out := values[:0:0]
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,239 @@
+package store_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/store"
+)
+
+// runNext claims the next job and finishes it as completed or failed.
+func runNext(t *testing.T, repo store.JobStore, succeed bool) *job.Job {
+	t.Helper()
+	j, err := repo.PreventRaceCondition(store.ClaimOptions{WorkerID: "w", Lease: time.Minute})
+	if err != nil || j == nil {
+		t.Fatalf("expected a runnable job: %v", err)
+	}
+	if succeed {
+		err = repo.MarkCompleted(j)
+	} else {
+		err = repo.Failed(j, "boom", time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func stateOf(t *testing.T, repo store.JobStore, id string) job.JobState {
+	t.Helper()
+	j, err := repo.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j.State
+}
+
+func TestWorkflowRunsInDependencyOrder(t *testing.T) {
+	repo := newTestRepo(t)
+
+	err := repo.CreateWorkflow([]*job.Job{
+		{ID: "load", Command: "true", DependsOn: []string{"transform"}, MaxRetries: 1},
+		{ID: "extract", Command: "true", MaxRetries: 1},
+		{ID: "transform", Command: "true", DependsOn: []string{"extract"}, MaxRetries: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := stateOf(t, repo, "load"); s != job.StateBlocked {
+		t.Fatalf("load should start blocked, got %s", s)
+	}
+
+	for _, want := range []string{"extract", "transform", "load"} {
+		if got := runNext(t, repo, true); got.ID != want {
+			t.Fatalf("expected %s to run next, got %s", want, got.ID)
+		}
+	}
+}
+
+func TestDeadParentCascadesOrIsSkipped(t *testing.T) {
+	repo := newTestRepo(t)
+
+	err := repo.CreateWorkflow([]*job.Job{
+		{ID: "parent", Command: "false", MaxRetries: 1},
+		{ID: "cascades", Command: "true", DependsOn: []string{"parent"}, MaxRetries: 1},
+		{ID: "grandchild", Command: "true", DependsOn: []string{"cascades"}, MaxRetries: 1},
+		{ID: "skips", Command: "true", DependsOn: []string{"parent"}, OnParentDead: job.OnParentDeadSkip, MaxRetries: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runNext(t, repo, false) // parent has one try, so it goes straight to the DLQ
+
+	if s := stateOf(t, repo, "cascades"); s != job.StateDead {
+		t.Fatalf("cascades should be dead, got %s", s)
+	}
+	if s := stateOf(t, repo, "grandchild"); s != job.StateDead {
+		t.Fatalf("grandchild should be dead, got %s", s)
+	}
+	if s := stateOf(t, repo, "skips"); s != job.StatePending {
+		t.Fatalf("skips should be pending, got %s", s)
+	}
+}
+
+func TestWorkflowRejectsCyclesAtomically(t *testing.T) {
+	repo := newTestRepo(t)
+
+	err := repo.CreateWorkflow([]*job.Job{
+		{ID: "ok", Command: "true"},
+		{ID: "a", Command: "true", DependsOn: []string{"b"}},
+		{ID: "b", Command: "true", DependsOn: []string{"a"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+	if _, err := repo.Get("ok"); err == nil {
+		t.Fatal("no job should be stored when the workflow is rejected")
+	}
+
+	if err := repo.Create(&job.Job{ID: "orphan", Command: "true", DependsOn: []string{"missing"}}); err == nil {
+		t.Fatal("expected an error for an unknown dependency")
+	}
+}
+
+func TestCancelPendingAndRunningJobs(t *testing.T) {
+	repo := newTestRepo(t)
+
+	if err := repo.CreateWorkflow([]*job.Job{
+		{ID: "parent", Command: "true", MaxRetries: 1},
+		{ID: "child", Command: "true", DependsOn: []string{"parent"}, OnParentDead: job.OnParentDeadSkip, MaxRetries: 1},
+		{ID: "running", Command: "sleep 60", Priority: 10, MaxRetries: 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	running, err := repo.PreventRaceCondition(store.ClaimOptions{WorkerID: "w", Lease: time.Minute})
+	if err != nil || running == nil || running.ID != "running" {
+		t.Fatalf("expected to claim the running job: %v", err)
+	}
+
+	if _, err := repo.Cancel("parent"); err != nil {
+		t.Fatal(err)
+	}
+	if s := stateOf(t, repo, "parent"); s != job.StateCancelled {
+		t.Fatalf("pending job should be cancelled at once, got %s", s)
+	}
+	if s := stateOf(t, repo, "child"); s != job.StatePending {
+		t.Fatalf("a skip dependent of a cancelled parent should be released, got %s", s)
+	}
+
+	j, err := repo.Cancel("running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.State != job.StateProcessing || !j.CancelRequested {
+		t.Fatalf("a running job should only be flagged, got %s", j.State)
+	}
+	requested, err := repo.Heartbeat("running", "w", time.Minute)
+	if err != nil || !requested {
+		t.Fatalf("heartbeat should report the cancel request: %v", err)
+	}
+	if err := repo.MarkCancelled(running); err != nil {
+		t.Fatal(err)
+	}
+	if s := stateOf(t, repo, "running"); s != job.StateCancelled {
+		t.Fatalf("expected cancelled, got %s", s)
+	}
+
+	if _, err := repo.Cancel("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCreateBatchAtomicAndBestEffort(t *testing.T) {
+	repo := newTestRepo(t)
+	if err := repo.Create(&job.Job{ID: "taken", Command: "true"}); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := func() []*job.Job {
+		return []*job.Job{
+			{ID: "a", Command: "true"},
+			{ID: "taken", Command: "true"},
+			{ID: "b", Command: "true", DependsOn: []string{"a"}},
+			{ID: "c", Command: "true", DependsOn: []string{"missing"}},
+			{ID: "d", Command: "true", DependsOn: []string{"c"}},
+		}
+	}
+
+	rejected, err := repo.CreateBatch(batch(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rejected) != 1 || rejected[0].Index != 1 {
+		t.Fatalf("atomic batch should reject the taken id first, got %+v", rejected)
+	}
+	if _, err := repo.Get("a"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatal("an atomic batch with a rejected job must insert nothing")
+	}
+
+	rejected, err = repo.CreateBatch(batch(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indexes []int
+	for _, r := range rejected {
+		indexes = append(indexes, r.Index)
+	}
+	if len(indexes) != 3 || indexes[0] != 1 || indexes[1] != 3 || indexes[2] != 4 {
+		t.Fatalf("expected jobs 1, 3 and 4 rejected, got %v", indexes)
+	}
+	if s := stateOf(t, repo, "b"); s != job.StateBlocked {
+		t.Fatalf("b should wait for a, got %s", s)
+	}
+}
+
+func TestCreateBatchReportsIDsTakenDuringTheBatch(t *testing.T) {
+	for _, atomic := range []bool{false, true} {
+		repo := newTestRepo(t)
+		// Another client inserts "b" right after the batch looked up which
+		// of its IDs were free.
+		raced := false
+		err := repo.DB().Callback().Query().After("gorm:query").Register("test:race", func(db *gorm.DB) {
+			if raced || db.Statement.Table != "jobs" {
+				return
+			}
+			raced = true
+			if err := repo.Create(&job.Job{ID: "b", Command: "true"}); err != nil {
+				t.Error(err)
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rejected, err := repo.CreateBatch([]*job.Job{
+			{ID: "a", Command: "true"},
+			{ID: "b", Command: "echo mine"},
+			{ID: "c", Command: "true"},
+		}, atomic)
+		if err != nil {
+			t.Fatalf("atomic=%v: batch failed outright: %v", atomic, err)
+		}
+		if len(rejected) != 1 || rejected[0].Index != 1 || !errors.Is(rejected[0].Err, store.ErrJobExists) {
+			t.Fatalf("atomic=%v: expected job #2 rejected as existing, got %+v", atomic, rejected)
+		}
+		_, errA := repo.Get("a")
+		if atomic != errors.Is(errA, store.ErrNotFound) {
+			t.Fatalf("atomic=%v: Get(a) = %v", atomic, errA)
+		}
+		if b, _ := repo.Get("b"); b.Command != "true" {
+			t.Fatalf("atomic=%v: the other client's b was overwritten: %q", atomic, b.Command)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package store
+
+import (
+	"gorm.io/gorm"
+	"queuectl.backend/internal/job"
+)
+
+// CancelLoaded cancels j as it was loaded, without reading it again, so tests
+// can cancel a job that changed state since.
+func CancelLoaded(s JobStore, j *job.Job) error {
+	return s.DB().Transaction(func(tx *gorm.DB) error {
+		return cancelInTx(tx, j)
+	})
+}
+
+// RetryLoaded retries j as it was loaded, like CancelLoaded.
+func RetryLoaded(s JobStore, j *job.Job) error {
+	return s.DB().Transaction(func(tx *gorm.DB) error {
+		return retryDeadInTx(tx, j)
+	})
+}
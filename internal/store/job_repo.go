@@ -2,18 +2,29 @@ package store
 
 import (
 	"errors"
+	"fmt"
+	"slices"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"queuectl.backend/internal/job"
 )
 
-// JobRepo handles all DB operations for jobs.
+// ErrLeaseLost is returned when a worker tries to renew or finish a job it no
+// longer owns, typically because the reaper already reclaimed it.
+var ErrLeaseLost = errors.New("job lease lost")
+
+// JobRepo handles all DB operations for jobs. It implements JobStore on top
+// of GORM; the driver-specific behavior is limited to how claims lock rows.
 type JobRepo struct {
 	db *gorm.DB
+	// skipLocked adds FOR UPDATE SKIP LOCKED to the claim query. SQLite has no
+	// row locks and serializes writers instead, so only PostgreSQL sets it.
+	skipLocked bool
 }
 
-// NewJobRepo creates a new repository instance.
+// NewJobRepo creates a new repository instance for a SQLite database.
 func NewJobRepo(db *gorm.DB) *JobRepo {
 	return &JobRepo{db: db}
 }
@@ -23,9 +34,49 @@ func (r *JobRepo) Create(j *job.Job) error {
 	if j == nil {
 		return errors.New("job cannot be nil")
 	}
+	if j.Queue == "" {
+		j.Queue = job.DefaultQueue
+	}
 	j.CreatedAt = time.Now().UTC()
 	j.UpdatedAt = j.CreatedAt
-	return r.db.Create(j).Error
+	for retried := false; ; retried = true {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			return createJob(tx, j)
+		})
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return err
+		}
+		// A concurrent insert took the ID or key between our checks and the
+		// insert; look again to report which.
+		if retried {
+			return jobExistsError(j.ID)
+		}
+	}
+}
+
+// CreateInTx inserts j inside tx like Create does: dependencies get their
+// edges and state, idempotency keys are enforced and the job is validated.
+func (r *JobRepo) CreateInTx(tx *gorm.DB, j *job.Job) error {
+	if j.Queue == "" {
+		j.Queue = job.DefaultQueue
+	}
+	if j.CreatedAt.IsZero() {
+		j.CreatedAt = time.Now().UTC()
+		j.UpdatedAt = j.CreatedAt
+	}
+	return createJob(tx, j)
+}
+
+// Get fetches a single job by ID.
+func (r *JobRepo) Get(id string) (*job.Job, error) {
+	var j job.Job
+	if err := r.db.Where("id = ?", id).Limit(1).Find(&j).Error; err != nil {
+		return nil, err
+	}
+	if j.ID == "" {
+		return nil, ErrNotFound
+	}
+	return &j, nil
 }
 
 // FindPending fetches the next job ready for execution.
@@ -71,54 +122,354 @@ func (r *JobRepo) MarkCompleted(j *job.Job) error {
 		return errors.New("job cannot be nil")
 	}
 	j.State = job.StateCompleted
-	return r.Update(j)
+	return r.finish(j, false, "state", "exit_code", "output", "duration")
+}
+
+// MarkCancelled records that a running job was killed on request.
+func (r *JobRepo) MarkCancelled(j *job.Job) error {
+	if j == nil {
+		return errors.New("job cannot be nil")
+	}
+	msg := "cancelled while running"
+	j.State = job.StateCancelled
+	j.LastError = &msg
+	j.RunAt = nil
+	return r.finish(j, false, "state", "last_error", "run_at", "output", "duration")
 }
 
-// Failed handles retry or moves the job to the DLQ after max retries.
+// Failed handles retry or moves the job to the DLQ after max retries. A job
+// someone asked to cancel is recorded as cancelled instead.
 func (r *JobRepo) Failed(j *job.Job, errMsg string, baseDelay time.Duration) error {
 	if j == nil {
 		return errors.New("job cannot be nil")
 	}
+	applyFailure(j, errMsg, baseDelay, time.Now().UTC())
+	return r.finishFailure(j, "state", "attempts", "exit_code", "last_error", "run_at", "output", "duration")
+}
 
+// MarkDead counts a failed attempt and moves the job straight to the DLQ,
+// for failures that retrying cannot fix. Like Failed, it records a job
+// someone asked to cancel as cancelled.
+func (r *JobRepo) MarkDead(j *job.Job, errMsg string) error {
+	if j == nil {
+		return errors.New("job cannot be nil")
+	}
+	j.Attempts++
+	j.LastError = &errMsg
+	j.State = job.StateDead
+	j.RunAt = nil
+	return r.finishFailure(j, "state", "attempts", "exit_code", "last_error", "run_at", "output", "duration")
+}
+
+// finishFailure persists a failed attempt unless a cancel was requested
+// while the job ran: the failure would retry the job or make it retryable
+// from the DLQ, so the job is marked cancelled instead.
+func (r *JobRepo) finishFailure(j *job.Job, columns ...string) error {
+	err := r.finish(j, true, columns...)
+	if errors.Is(err, errCancelRequested) {
+		return r.MarkCancelled(j)
+	}
+	return err
+}
+
+// errCancelRequested is returned by finish when it leaves a job alone
+// because someone asked for it to be cancelled.
+var errCancelRequested = errors.New("cancel requested")
+
+// applyFailure counts a failed attempt and either schedules a retry using the
+// job's backoff policy (exponential from baseDelay by default) or moves the
+// job to the DLQ after max retries.
+func applyFailure(j *job.Job, errMsg string, baseDelay time.Duration, now time.Time) {
 	j.Attempts++
 	j.LastError = &errMsg
-	now := time.Now().UTC()
 
 	if j.Attempts >= j.MaxRetries {
 		// Move to DLQ
 		j.State = job.StateDead
 		j.RunAt = nil
 	} else {
-		// Schedule retry with exponential backoff
+		// Schedule retry with the job's backoff policy
 		j.State = job.StateFailed
-		delay := baseDelay * time.Duration(1<<(j.Attempts-1))
-		nextRun := now.Add(delay)
+		nextRun := now.Add(j.Backoff.Delay(j.Attempts, baseDelay))
 		j.RunAt = &nextRun
 	}
+}
 
-	j.UpdatedAt = now
-	return r.db.Save(j).Error
+// finish persists the outcome of a claimed job and releases its lease. When the
+// job carries a worker ID the write only applies while that worker still owns
+// it, so a reaped job that was handed to someone else is never overwritten.
+// Jobs waiting on j are released in the same transaction once it completes or dies.
+// With unlessCancelled set, a job with a pending cancel is left alone and
+// errCancelRequested returned.
+func (r *JobRepo) finish(j *job.Job, unlessCancelled bool, columns ...string) error {
+	j.UpdatedAt = time.Now().UTC()
+	j.LeaseExpiresAt = nil
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		owned := func(q *gorm.DB) *gorm.DB {
+			q = q.Where("id = ?", j.ID)
+			if j.WorkerID != nil {
+				q = q.Where("state = ? AND worker_id = ?", job.StateProcessing, *j.WorkerID)
+			}
+			return q
+		}
+		query := owned(tx.Model(j).Select(append(columns, "lease_expires_at", "updated_at")))
+		if unlessCancelled {
+			query = query.Where("cancel_requested = ?", false)
+		}
+		res := query.Updates(j)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			if unlessCancelled {
+				var cancelled int64
+				if err := owned(tx.Model(&job.Job{})).Where("cancel_requested = ?", true).Count(&cancelled).Error; err != nil {
+					return err
+				}
+				if cancelled > 0 {
+					return errCancelRequested
+				}
+			}
+			return ErrLeaseLost
+		}
+		if j.State == job.StateCompleted || j.State == job.StateDead || j.State == job.StateCancelled {
+			return releaseDependents(tx, j.ID)
+		}
+		return nil
+	})
+}
+
+// Heartbeat extends the lease on a job the worker is still executing and
+// reports whether someone asked for the job to be cancelled.
+// It returns ErrLeaseLost if the job is no longer owned by workerId.
+func (r *JobRepo) Heartbeat(jobID, workerId string, lease time.Duration) (bool, error) {
+	res := r.db.Model(&job.Job{}).
+		Where("id = ? AND state = ? AND worker_id = ?", jobID, job.StateProcessing, workerId).
+		Update("lease_expires_at", time.Now().UTC().Add(lease))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, ErrLeaseLost
+	}
+
+	var cancelRequested bool
+	if err := r.db.Model(&job.Job{}).Select("cancel_requested").Where("id = ?", jobID).Scan(&cancelRequested).Error; err != nil {
+		return false, err
+	}
+	return cancelRequested, nil
 }
 
-// ListJobs retrieves jobs filtered by states and sorted by priority + creation time.
-func (r *JobRepo) ListJobs(states []job.JobState, limit, offset int32, newestFirst bool) ([]job.Job, error) {
+// Cancel stops a job. Jobs that have not started yet (pending, failed or
+// blocked) become cancelled immediately; for a processing job the owning
+// worker is asked to kill it, and the returned job is still processing.
+func (r *JobRepo) Cancel(id string) (*job.Job, error) {
+	var j job.Job
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", id).Limit(1).Find(&j).Error; err != nil {
+			return err
+		}
+		if j.ID == "" {
+			return ErrNotFound
+		}
+		return cancelInTx(tx, &j)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// CancelAll cancels every unfinished job matching f, ignoring its paging
+// fields, and returns the jobs it touched. Jobs that finish meanwhile are
+// left out.
+func (r *JobRepo) CancelAll(f JobFilter) ([]job.Job, error) {
+	query, err := applyFilter(r.db.Where("state IN ?", cancellableStates), f)
+	if err != nil {
+		return nil, err
+	}
 	var jobs []job.Job
+	if err := query.Find(&jobs).Error; err != nil {
+		return nil, err
+	}
 
-	if limit <= 0 {
-		limit = 100
+	cancelled := jobs[:0]
+	for i := range jobs {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			return cancelInTx(tx, &jobs[i])
+		})
+		if errors.Is(err, ErrConflict) {
+			continue // it finished meanwhile
+		}
+		if err != nil {
+			return cancelled, err
+		}
+		cancelled = append(cancelled, jobs[i])
 	}
+	return cancelled, nil
+}
 
-	order := "priority DESC, created_at ASC"
-	if newestFirst {
-		order = "priority DESC, created_at DESC"
+// cancellableStates are the states a job can be cancelled from.
+var cancellableStates = []job.JobState{job.StatePending, job.StateFailed, job.StateBlocked, job.StateProcessing}
+
+func cancelInTx(tx *gorm.DB, j *job.Job) error {
+	now := time.Now().UTC()
+	switch j.State {
+	case job.StateProcessing:
+		j.CancelRequested = true
+		res := tx.Model(j).Where("state = ?", job.StateProcessing).Update("cancel_requested", true)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errorf(ErrConflict, "job %s finished before it could be cancelled", j.ID)
+		}
+		return nil
+	case job.StatePending, job.StateFailed, job.StateBlocked:
+		msg := "cancelled before running"
+		prev := j.State
+		j.State = job.StateCancelled
+		j.LastError = &msg
+		j.RunAt = nil
+		j.UpdatedAt = now
+		res := tx.Model(j).Where("state = ?", prev).
+			Select("state", "last_error", "run_at", "updated_at").Updates(j)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errorf(ErrConflict, "job %s changed state while cancelling; try again", j.ID)
+		}
+		return releaseDependents(tx, j.ID)
+	default:
+		return errorf(ErrConflict, "job %s is already %s", j.ID, j.State)
 	}
+}
 
-	query := r.db.Order(order).Offset(int(offset))
-	if len(states) > 0 {
-		query = query.Where("state IN ?", states)
+// Delete soft-deletes a job. A running job must be cancelled first; other
+// unfinished jobs are cancelled on the way, so their dependents are released
+// as after "queuectl cancel". Attempts and logs are kept.
+func (r *JobRepo) Delete(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var j job.Job
+		if err := tx.Where("id = ?", id).Limit(1).Find(&j).Error; err != nil {
+			return err
+		}
+		if j.ID == "" {
+			return ErrNotFound
+		}
+		if j.State == job.StateProcessing {
+			return errorf(ErrConflict, "job %s is running; cancel it first", j.ID)
+		}
+		if !j.State.Finished() {
+			if err := cancelInTx(tx, &j); err != nil {
+				return err
+			}
+		}
+		// Touch updated_at as well, so WatchJobs reports the deletion.
+		now := time.Now().UTC()
+		return tx.Model(&j).Updates(map[string]any{"deleted_at": now, "updated_at": now}).Error
+	})
+}
+
+// ReapExpiredLeases returns processing jobs whose lease has run out to the
+// retry path, counting the lost run as a failed attempt (or moving the job to
+// the DLQ when it has no retries left). Jobs claimed before leases existed
+// have no expiry at all and are reclaimed as well.
+func (r *JobRepo) ReapExpiredLeases(baseDelay time.Duration) (int, error) {
+	now := time.Now().UTC()
+
+	var expired []job.Job
+	if err := r.db.
+		Where("state = ? AND (lease_expires_at IS NULL OR lease_expires_at < ?)", job.StateProcessing, now).
+		Find(&expired).Error; err != nil {
+		return 0, err
 	}
 
-	if err := query.Find(&jobs).Error; err != nil {
+	reaped := 0
+	for i := range expired {
+		j := &expired[i]
+		owner := "unknown worker"
+		if j.WorkerID != nil {
+			owner = *j.WorkerID
+		}
+		if j.CancelRequested {
+			msg := fmt.Sprintf("cancelled: %s stopped heartbeating before killing the job", owner)
+			j.State = job.StateCancelled
+			j.LastError = &msg
+			j.RunAt = nil
+		} else {
+			applyFailure(j, fmt.Sprintf("lease expired: %s stopped heartbeating", owner), baseDelay, now)
+		}
+		j.LeaseExpiresAt = nil
+		j.UpdatedAt = now
+
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			// Re-check the expiry so a worker that heartbeated meanwhile keeps its job.
+			res := tx.Model(j).
+				Select("state", "attempts", "last_error", "run_at", "lease_expires_at", "updated_at").
+				Where("state = ? AND (lease_expires_at IS NULL OR lease_expires_at < ?)", job.StateProcessing, now).
+				Updates(j)
+			if res.Error != nil {
+				return res.Error
+			}
+			reaped += int(res.RowsAffected)
+			if res.RowsAffected == 0 {
+				return nil
+			}
+			if err := closeOpenAttempts(tx, j.ID, j.State, *j.LastError, now); err != nil {
+				return err
+			}
+			if j.State == job.StateDead || j.State == job.StateCancelled {
+				return releaseDependents(tx, j.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			return reaped, err
+		}
+	}
+	return reaped, nil
+}
+
+// ListJobs returns one page of jobs matching f in f.Sort order. Pass
+// JobCursor of the last job as f.Cursor to fetch the next page.
+func (r *JobRepo) ListJobs(f JobFilter) ([]job.Job, error) {
+	var jobs []job.Job
+
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+
+	query, err := applyFilter(r.db, f)
+	if err != nil {
+		return nil, err
+	}
+
+	var after cursor
+	if f.Cursor != "" {
+		if after, err = parseCursor(f.Cursor); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := sortKeys(f, after)
+	if err != nil {
+		return nil, err
+	}
+	if f.Cursor != "" {
+		cond, args := keysetCondition(keys)
+		query = query.Where(cond, args...)
+	}
+	for _, k := range keys {
+		dir := " ASC"
+		if k.desc {
+			dir = " DESC"
+		}
+		query = query.Order(k.column + dir)
+	}
+
+	if err := query.Limit(int(f.Limit)).Offset(int(f.Offset)).Find(&jobs).Error; err != nil {
 		return nil, err
 	}
 
@@ -126,19 +477,32 @@ func (r *JobRepo) ListJobs(states []job.JobState, limit, offset int32, newestFir
 }
 
 // PreventRaceCondition ensures that only one worker safely claims a job.
-// It includes retryable (failed) jobs once their run_at time is due.
-func (r *JobRepo) PreventRaceCondition(workerId string) (*job.Job, error) {
+// It includes retryable (failed) jobs once their run_at time is due, only
+// considers the queues in opts.Queues (all when empty), and records the worker
+// as the owner with a lease that must be renewed through Heartbeat before it
+// expires.
+func (r *JobRepo) PreventRaceCondition(opts ClaimOptions) (*job.Job, error) {
 	now := time.Now().UTC()
 	tx := r.db.Begin()
 	if tx.Error != nil {
 		return nil, tx.Error
 	}
 
+	leaseExpiresAt := now.Add(opts.Lease)
+
+	query := tx
+	if r.skipLocked {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+	}
+	if len(opts.Queues) > 0 {
+		query = query.Where("queue IN ?", opts.Queues)
+	}
+
 	var j job.Job
-	err := tx.
+	err := query.
 		Where("(state = ? OR state = ?) AND (run_at IS NULL OR run_at <= ?)",
 			job.StatePending, job.StateFailed, now).
-		Order("priority DESC, created_at ASC").
+		Order(r.claimOrder(now, opts.AgingStep)).
 		Limit(1).
 		Take(&j).Error
 
@@ -155,8 +519,12 @@ func (r *JobRepo) PreventRaceCondition(workerId string) (*job.Job, error) {
 		Where("id = ? AND (state = ? OR state = ?)",
 			j.ID, job.StatePending, job.StateFailed).
 		Updates(map[string]interface{}{
-			"state":      job.StateProcessing,
-			"updated_at": now,
+			"state":            job.StateProcessing,
+			"worker_id":        opts.WorkerID,
+			"lease_expires_at": leaseExpiresAt,
+			"updated_at":       now,
+			// A cancel meant for an earlier run must not kill this one.
+			"cancel_requested": false,
 		})
 
 	if res.Error != nil {
@@ -173,24 +541,212 @@ func (r *JobRepo) PreventRaceCondition(workerId string) (*job.Job, error) {
 	}
 
 	j.State = job.StateProcessing
+	j.WorkerID = &opts.WorkerID
+	j.LeaseExpiresAt = &leaseExpiresAt
+	j.CancelRequested = false
+	return &j, nil
+}
+
+// RetryDead moves a job from the DLQ back to pending, resetting its attempts,
+// schedule and last error.
+func (r *JobRepo) RetryDead(id string) (*job.Job, error) {
+	var j job.Job
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", id).Limit(1).Find(&j).Error; err != nil {
+			return err
+		}
+		if j.ID == "" {
+			return ErrNotFound
+		}
+		return retryDeadInTx(tx, &j)
+	})
+	if err != nil {
+		return nil, err
+	}
 	return &j, nil
 }
 
+// retryDeadInTx resets j as loaded, failing with ErrConflict unless it is
+// still dead when the update runs.
+func retryDeadInTx(tx *gorm.DB, j *job.Job) error {
+	if j.State != job.StateDead {
+		return errorf(ErrConflict, "job %s is %s, not in the DLQ", j.ID, j.State)
+	}
+
+	j.State = job.StatePending
+	j.Attempts = 0
+	j.RunAt = nil
+	j.LastError = nil
+	j.CancelRequested = false
+	if len(j.DependsOn) > 0 {
+		// Wait again for unfinished dependencies; refuse if one is still dead.
+		if err := lockParents(tx, j.DependsOn); err != nil {
+			return err
+		}
+		if err := resolveDependencies(tx, j); err != nil {
+			return err
+		}
+		if j.State == job.StateDead {
+			return errorf(ErrConflict, "cannot retry %s: %s", j.ID, *j.LastError)
+		}
+	}
+	j.UpdatedAt = time.Now().UTC()
+	res := tx.Model(j).Where("state = ?", job.StateDead).
+		Select("state", "attempts", "run_at", "last_error", "cancel_requested", "updated_at").Updates(j)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errorf(ErrConflict, "job %s changed state meanwhile; try again", j.ID)
+	}
+	return nil
+}
+
+// SetPriority changes the priority of a job no worker has claimed yet.
+func (r *JobRepo) SetPriority(id string, priority int) (*job.Job, error) {
+	return r.updateWaiting(id, "reprioritize", []job.JobState{job.StatePending, job.StateFailed, job.StateBlocked},
+		func(j *job.Job) { j.Priority = priority }, "priority")
+}
+
+// RunNow makes a job that waits for its run_at, such as a delayed job or a
+// retry backing off, runnable at once.
+func (r *JobRepo) RunNow(id string) (*job.Job, error) {
+	return r.updateWaiting(id, "run", []job.JobState{job.StatePending, job.StateFailed},
+		func(j *job.Job) { j.RunAt = nil }, "run_at")
+}
+
+// updateWaiting applies change to a job in one of states and saves columns,
+// failing with ErrConflict if the job is in another state or a worker
+// claims it meanwhile.
+func (r *JobRepo) updateWaiting(id, action string, states []job.JobState, change func(*job.Job), columns ...string) (*job.Job, error) {
+	var j job.Job
+	if err := r.db.Where("id = ?", id).Limit(1).Find(&j).Error; err != nil {
+		return nil, err
+	}
+	if j.ID == "" {
+		return nil, ErrNotFound
+	}
+	if !slices.Contains(states, j.State) {
+		return nil, errorf(ErrConflict, "cannot %s job %s: it is %s", action, j.ID, j.State)
+	}
+
+	prev := j.State
+	change(&j)
+	j.UpdatedAt = time.Now().UTC()
+	res := r.db.Model(&j).Where("state = ?", prev).Select(append(columns, "updated_at")).Updates(&j)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, errorf(ErrConflict, "job %s changed state meanwhile; try again", j.ID)
+	}
+	return &j, nil
+}
+
+// claimOrder returns the ORDER BY used to pick the next job. Without aging
+// it is strict priority; with aging every agingStep a job has been runnable
+// adds one to its effective priority, so long-waiting jobs eventually beat
+// a steady stream of higher-priority work.
+func (r *JobRepo) claimOrder(now time.Time, agingStep time.Duration) interface{} {
+	if agingStep <= 0 {
+		return "priority DESC, created_at ASC"
+	}
+
+	// Seconds since the job became runnable: its retry/schedule time, else creation.
+	waited := "(julianday(?) - julianday(COALESCE(run_at, created_at))) * 86400"
+	if r.db.Dialector.Name() == "postgres" {
+		waited = "EXTRACT(EPOCH FROM (?::timestamptz - COALESCE(run_at, created_at)))"
+	}
+	return clause.OrderBy{
+		Expression: clause.Expr{
+			SQL:                "priority + " + waited + " / ? DESC, created_at ASC",
+			Vars:               []interface{}{now, agingStep.Seconds()},
+			WithoutParentheses: true,
+		},
+	}
+}
+
 // DB returns the underlying database instance.
 func (r *JobRepo) DB() *gorm.DB {
 	return r.db
 }
 
+// Close closes the underlying database.
+func (r *JobRepo) Close() error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
 // MetricsSummary aggregates queue metrics.
 type MetricsSummary struct {
-	Total       int64
-	Pending     int64
-	Processing  int64
-	Completed   int64
-	Failed      int64
-	Dead        int64
-	AvgDuration float64
-	AvgRetries  float64
+	Total       int64   `json:"total"`
+	Pending     int64   `json:"pending"`
+	Processing  int64   `json:"processing"`
+	Completed   int64   `json:"completed"`
+	Failed      int64   `json:"failed"`
+	Dead        int64   `json:"dead"`
+	Blocked     int64   `json:"blocked"`
+	Cancelled   int64   `json:"cancelled"`
+	AvgDuration float64 `json:"avg_duration"`
+	AvgRetries  float64 `json:"avg_retries"`
+}
+
+// QueueSummary holds per-state job counts for one queue.
+type QueueSummary struct {
+	Queue      string `json:"queue"`
+	Total      int64  `json:"total"`
+	Pending    int64  `json:"pending"`
+	Processing int64  `json:"processing"`
+	Completed  int64  `json:"completed"`
+	Failed     int64  `json:"failed"`
+	Dead       int64  `json:"dead"`
+	Blocked    int64  `json:"blocked"`
+	Cancelled  int64  `json:"cancelled"`
+}
+
+// QueueMetrics returns per-state counts for every queue that has jobs, sorted by queue name.
+func (r *JobRepo) QueueMetrics() ([]QueueSummary, error) {
+	var rows []struct {
+		Queue string
+		State job.JobState
+		Count int64
+	}
+	if err := r.db.Model(&job.Job{}).
+		Select("queue, state, COUNT(*) AS count").
+		Group("queue, state").
+		Order("queue").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var summaries []QueueSummary
+	for _, row := range rows {
+		if len(summaries) == 0 || summaries[len(summaries)-1].Queue != row.Queue {
+			summaries = append(summaries, QueueSummary{Queue: row.Queue})
+		}
+		s := &summaries[len(summaries)-1]
+		s.Total += row.Count
+		switch row.State {
+		case job.StatePending:
+			s.Pending = row.Count
+		case job.StateProcessing:
+			s.Processing = row.Count
+		case job.StateCompleted:
+			s.Completed = row.Count
+		case job.StateFailed:
+			s.Failed = row.Count
+		case job.StateDead:
+			s.Dead = row.Count
+		case job.StateBlocked:
+			s.Blocked = row.Count
+		case job.StateCancelled:
+			s.Cancelled = row.Count
+		}
+	}
+	return summaries, nil
 }
 
 // JobMetrics returns system-wide metrics and averages.
@@ -206,6 +762,8 @@ func (r *JobRepo) JobMetrics() (MetricsSummary, error) {
 	r.db.Model(&job.Job{}).Where("state = ?", job.StateCompleted).Count(&summary.Completed)
 	r.db.Model(&job.Job{}).Where("state = ?", job.StateFailed).Count(&summary.Failed)
 	r.db.Model(&job.Job{}).Where("state = ?", job.StateDead).Count(&summary.Dead)
+	r.db.Model(&job.Job{}).Where("state = ?", job.StateBlocked).Count(&summary.Blocked)
+	r.db.Model(&job.Job{}).Where("state = ?", job.StateCancelled).Count(&summary.Cancelled)
 
 	// Calculate averages
 	r.db.Model(&job.Job{}).Select("COALESCE(AVG(duration), 0)").Scan(&summary.AvgDuration)
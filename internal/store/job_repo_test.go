@@ -0,0 +1,760 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/metrics"
+	"queuectl.backend/internal/store"
+)
+
+// newTestRepo returns an empty store: in memory, or the PostgreSQL database
+// named by $QUEUECTL_TEST_PG when set.
+func newTestRepo(t *testing.T) store.JobStore {
+	t.Helper()
+	if dsn := os.Getenv(pgDSNEnv); dsn != "" {
+		return openTestPostgres(t, dsn)
+	}
+	repo, err := store.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+func TestExpiredLeaseIsReclaimed(t *testing.T) {
+	repo := newTestRepo(t)
+
+	if err := repo.Create(&job.Job{ID: "lease-job", Command: "sleep 60", MaxRetries: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := repo.PreventRaceCondition(store.ClaimOptions{WorkerID: "worker-a", Lease: -time.Second}) // already expired
+	if err != nil || claimed == nil {
+		t.Fatalf("claim failed: %v %+v", err, claimed)
+	}
+	if claimed.WorkerID == nil || *claimed.WorkerID != "worker-a" {
+		t.Fatalf("expected claim to record worker-a, got %v", claimed.WorkerID)
+	}
+
+	n, err := repo.ReapExpiredLeases(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 reclaimed job, got %d", n)
+	}
+
+	var reaped job.Job
+	if err := repo.DB().First(&reaped, "id = ?", "lease-job").Error; err != nil {
+		t.Fatal(err)
+	}
+	if reaped.State != job.StateFailed || reaped.Attempts != 1 || reaped.LeaseExpiresAt != nil {
+		t.Fatalf("unexpected reaped job: state=%s attempts=%d lease=%v", reaped.State, reaped.Attempts, reaped.LeaseExpiresAt)
+	}
+
+	// The original owner must not be able to overwrite the reclaimed job.
+	if _, err := repo.Heartbeat(claimed.ID, "worker-a", time.Minute); !errors.Is(err, store.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost from heartbeat, got %v", err)
+	}
+	if err := repo.MarkCompleted(claimed); !errors.Is(err, store.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost from completion, got %v", err)
+	}
+}
+
+func TestHeartbeatKeepsLease(t *testing.T) {
+	repo := newTestRepo(t)
+
+	if err := repo.Create(&job.Job{ID: "busy-job", Command: "sleep 60", MaxRetries: 3}); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := repo.PreventRaceCondition(store.ClaimOptions{WorkerID: "worker-a", Lease: time.Minute})
+	if err != nil || claimed == nil {
+		t.Fatalf("claim failed: %v %+v", err, claimed)
+	}
+	if _, err := repo.Heartbeat(claimed.ID, "worker-a", time.Minute); err != nil {
+		t.Fatalf("heartbeat failed: %v", err)
+	}
+
+	n, err := repo.ReapExpiredLeases(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("expected live lease to survive, reclaimed %d", n)
+	}
+	if err := repo.MarkCompleted(claimed); err != nil {
+		t.Fatalf("owner failed to complete job: %v", err)
+	}
+}
+
+func TestMemoryStoresAreIsolated(t *testing.T) {
+	a, err := store.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := store.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if err := a.Create(&job.Job{ID: "only-in-a", Command: "true", MaxRetries: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Get("only-in-a"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected job to be invisible to a second memory store, got %v", err)
+	}
+	if _, err := a.Get("only-in-a"); err != nil {
+		t.Fatalf("expected job in its own store: %v", err)
+	}
+}
+
+func TestClosedStoreRefusesQueries(t *testing.T) {
+	repo, err := store.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get("any"); err == nil || errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Get on a closed store = %v, want a connection error", err)
+	}
+}
+
+func TestClaimOnlyFromSubscribedQueues(t *testing.T) {
+	repo := newTestRepo(t)
+
+	for _, j := range []*job.Job{
+		{ID: "report", Command: "true", Queue: "reports", Priority: 10, MaxRetries: 3},
+		{ID: "email", Command: "true", Queue: "emails", MaxRetries: 3},
+	} {
+		if err := repo.Create(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	claimed, err := repo.PreventRaceCondition(store.ClaimOptions{WorkerID: "w", Lease: time.Minute, Queues: []string{"emails"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed == nil || claimed.ID != "email" {
+		t.Fatalf("expected the emails job despite its lower priority, got %+v", claimed)
+	}
+
+	claimed, err = repo.PreventRaceCondition(store.ClaimOptions{WorkerID: "w", Lease: time.Minute, Queues: []string{"emails"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed != nil {
+		t.Fatalf("expected nothing left on emails, got %s", claimed.ID)
+	}
+
+	queues, err := repo.QueueMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queues) != 2 || queues[0].Queue != "emails" || queues[0].Processing != 1 || queues[1].Pending != 1 {
+		t.Fatalf("unexpected queue breakdown: %+v", queues)
+	}
+}
+
+func TestAgingLetsOldLowPriorityJobsWin(t *testing.T) {
+	repo := newTestRepo(t)
+
+	if err := repo.Create(&job.Job{ID: "old-cleanup", Command: "true", MaxRetries: 3}); err != nil {
+		t.Fatal(err)
+	}
+	// Backdate the low-priority job by an hour.
+	if err := repo.DB().Model(&job.Job{}).Where("id = ?", "old-cleanup").
+		Update("created_at", time.Now().UTC().Add(-time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Create(&job.Job{ID: "fresh-urgent", Command: "true", Priority: 10, MaxRetries: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	strict, err := repo.FindPending()
+	if err != nil || strict == nil || strict.ID != "fresh-urgent" {
+		t.Fatalf("strict order should pick fresh-urgent, got %+v (%v)", strict, err)
+	}
+
+	// One priority point per minute: an hour of waiting outranks priority 10.
+	aged, err := repo.PreventRaceCondition(store.ClaimOptions{WorkerID: "w", Lease: time.Minute, AgingStep: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aged == nil || aged.ID != "old-cleanup" {
+		t.Fatalf("aging should pick old-cleanup, got %+v", aged)
+	}
+}
+
+func TestAttemptHistory(t *testing.T) {
+	repo := newTestRepo(t)
+
+	if err := repo.Create(&job.Job{ID: "flaky", Command: "false", MaxRetries: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	first := &job.Attempt{JobID: "flaky", WorkerID: "worker-a"}
+	if err := repo.StartAttempt(first); err != nil {
+		t.Fatal(err)
+	}
+	code, msg := 1, "exit status 1"
+	first.ExitCode, first.Error, first.State, first.Stderr = &code, &msg, job.StateFailed, "boom"
+	if err := repo.FinishAttempt(first); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second attempt's worker dies; the reaper must close its row.
+	if _, err := repo.PreventRaceCondition(store.ClaimOptions{WorkerID: "worker-b", Lease: -time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.StartAttempt(&job.Attempt{JobID: "flaky", WorkerID: "worker-b"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ReapExpiredLeases(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	attempts, err := repo.ListAttempts("flaky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", len(attempts))
+	}
+	if a := attempts[0]; a.Number != 1 || a.Stderr != "boom" || a.ExitCode == nil || *a.ExitCode != 1 || a.FinishedAt == nil {
+		t.Fatalf("first attempt not recorded: %+v", a)
+	}
+	if a := attempts[1]; a.Number != 2 || a.FinishedAt == nil || a.State != job.StateFailed || a.Error == nil {
+		t.Fatalf("reaped attempt should be closed as failed: %+v", a)
+	}
+}
+
+func TestListJobsPagesWithCursor(t *testing.T) {
+	repo := newTestRepo(t)
+
+	base := time.Now().UTC().Add(-time.Hour)
+	for i := range 25 {
+		j := &job.Job{
+			ID:       fmt.Sprintf("job-%02d", i),
+			Command:  fmt.Sprintf("echo %d", i),
+			Priority: i % 3,
+			Attempts: int32(i % 4),
+		}
+		if err := repo.Create(j); err != nil {
+			t.Fatal(err)
+		}
+		// Create stamps the current time; backdate in groups of five to get ties.
+		createdAt := base.Add(time.Duration(i/5) * time.Minute)
+		if err := repo.DB().Model(j).Update("created_at", createdAt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, f := range []store.JobFilter{
+		{Sort: store.SortPriority},
+		{Sort: store.SortPriority, NewestFirst: true},
+		{Sort: store.SortCreated},
+		{Sort: store.SortCreated, NewestFirst: true},
+	} {
+		f.Limit = 100
+		all, err := repo.ListJobs(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 25 {
+			t.Fatalf("expected 25 jobs, got %d", len(all))
+		}
+
+		var paged []job.Job
+		f.Limit = 7
+		for {
+			page, err := repo.ListJobs(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paged = append(paged, page...)
+			if len(page) < int(f.Limit) {
+				break
+			}
+			f.Cursor = store.JobCursor(&page[len(page)-1])
+		}
+		if len(paged) != len(all) {
+			t.Fatalf("sort %s newest=%v: paging returned %d jobs, want %d", f.Sort, f.NewestFirst, len(paged), len(all))
+		}
+		for i := range all {
+			if paged[i].ID != all[i].ID {
+				t.Fatalf("sort %s newest=%v: page order differs at %d: %s vs %s", f.Sort, f.NewestFirst, i, paged[i].ID, all[i].ID)
+			}
+		}
+	}
+
+	two := 2
+	matched, err := repo.ListJobs(store.JobFilter{
+		Priority:        &two,
+		MinAttempts:     1,
+		CommandContains: "echo 1",
+		Since:           base.Add(3 * time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Priority 2 with attempts >= 1, command "echo 1x", created in the last two groups.
+	if len(matched) != 1 || matched[0].ID != "job-17" {
+		t.Fatalf("unexpected filter result: %+v", matched)
+	}
+
+	// Matching ignores case on every backend.
+	matched, err = repo.ListJobs(store.JobFilter{CommandContains: "ECHO 2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matched) != 6 {
+		t.Fatalf("expected echo 2 and echo 20-24, got %d jobs", len(matched))
+	}
+
+	if _, err := repo.ListJobs(store.JobFilter{Cursor: "garbage"}); err == nil {
+		t.Fatal("expected an invalid cursor to be rejected")
+	}
+}
+
+func TestIdempotencyKeyDeduplicates(t *testing.T) {
+	repo := newTestRepo(t)
+
+	create := func(id, key, scope string) error {
+		return repo.Create(&job.Job{ID: id, Command: "echo " + id, IdempotencyKey: key, UniqueScope: scope})
+	}
+	isDuplicateOf := func(err error, id string) bool {
+		var dup *store.DuplicateError
+		return errors.As(err, &dup) && dup.ExistingID == id
+	}
+
+	if err := create("a1", "report", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := create("a2", "report", ""); !isDuplicateOf(err, "a1") {
+		t.Fatalf("forever key should stay held, got %v", err)
+	}
+	if err := create("a1", "", ""); !errors.Is(err, store.ErrJobExists) {
+		t.Fatalf("expected ErrJobExists for a taken ID, got %v", err)
+	}
+
+	// A pending-scoped key is released once its job leaves pending.
+	if err := create("p1", "sync", job.UniquePending); err != nil {
+		t.Fatal(err)
+	}
+	if err := create("p2", "sync", job.UniquePending); !isDuplicateOf(err, "p1") {
+		t.Fatalf("pending key should be held, got %v", err)
+	}
+	if err := repo.DB().Model(&job.Job{}).Where("id = ?", "p1").Update("state", job.StateCompleted).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := create("p3", "sync", job.UniquePending); err != nil {
+		t.Fatalf("released key should be reusable: %v", err)
+	}
+
+	// A window key expires with its window.
+	w := &job.Job{ID: "w1", Command: "echo w1", IdempotencyKey: "daily", UniqueFor: job.Duration(time.Hour)}
+	if err := repo.Create(w); err != nil {
+		t.Fatal(err)
+	}
+	if w.UniqueScope != job.UniqueWindow {
+		t.Fatalf("unique_for should imply the window scope, got %q", w.UniqueScope)
+	}
+	if err := create("w2", "daily", ""); !isDuplicateOf(err, "w1") {
+		t.Fatalf("window key should be held, got %v", err)
+	}
+	if err := repo.DB().Model(w).Update("created_at", time.Now().UTC().Add(-2*time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := create("w3", "daily", ""); err != nil {
+		t.Fatalf("expired window should release the key: %v", err)
+	}
+
+	// Duplicates in a batch are skipped without failing an atomic batch.
+	rejected, err := repo.CreateBatch([]*job.Job{
+		{ID: "b1", Command: "echo b1", IdempotencyKey: "report"},
+		{ID: "b2", Command: "echo b2", IdempotencyKey: "fresh"},
+		{ID: "b3", Command: "echo b3", IdempotencyKey: "fresh"},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rejected) != 2 || !isDuplicateOf(rejected[0].Err, "a1") || !isDuplicateOf(rejected[1].Err, "b2") {
+		t.Fatalf("unexpected batch result: %v", rejected)
+	}
+	if _, err := repo.Get("b2"); err != nil {
+		t.Fatalf("b2 should be inserted: %v", err)
+	}
+}
+
+func TestFollowLogsUntilJobFinishes(t *testing.T) {
+	repo := newTestRepo(t)
+	if err := repo.Create(&job.Job{ID: "tail", Command: "noisy"}); err != nil {
+		t.Fatal(err)
+	}
+	write := func(offset int64, data string) {
+		t.Helper()
+		c := &job.LogChunk{JobID: "tail", Attempt: 1, Stream: job.StreamStdout, Offset: offset, Data: data, CreatedAt: time.Now().UTC()}
+		if err := repo.AppendLog(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(0, "one\ntw")
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		write(6, "o\n")
+		write(100, "after the gap\nno newline") // bytes 8-99 were dropped
+		repo.DB().Model(&job.Job{}).Where("id = ?", "tail").Update("state", job.StateCompleted)
+	}()
+
+	var splitter job.LineSplitter
+	var lines []string
+	err := store.FollowLogs(context.Background(), repo, store.LogQuery{JobID: "tail"}, 5*time.Millisecond, func(chunks []job.LogChunk) error {
+		for _, c := range chunks {
+			for _, l := range splitter.Add(&c) {
+				lines = append(lines, l.Text)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range splitter.Flush() {
+		lines = append(lines, l.Text)
+	}
+	want := []string{"one", "two", "[... 92 bytes truncated ...]", "after the gap", "no newline"}
+	if fmt.Sprint(lines) != fmt.Sprint(want) {
+		t.Fatalf("lines = %q, want %q", lines, want)
+	}
+}
+
+func TestDeleteAndRetryReportErrorKinds(t *testing.T) {
+	repo := newTestRepo(t)
+
+	for _, id := range []string{"a", "b"} {
+		if err := repo.Create(&job.Job{ID: id, Command: "true", MaxRetries: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.RetryDead("a"); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("expected ErrConflict retrying a pending job, got %v", err)
+	}
+	if _, err := repo.RetryDead("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := repo.Create(&job.Job{ID: "c", Command: "true", DependsOn: []string{"nope"}}); !errors.Is(err, store.ErrInvalidJob) {
+		t.Fatalf("expected ErrInvalidJob for an unknown dependency, got %v", err)
+	}
+	if _, err := repo.ListJobs(store.JobFilter{Cursor: "garbage"}); !errors.Is(err, store.ErrInvalidFilter) {
+		t.Fatalf("expected ErrInvalidFilter, got %v", err)
+	}
+
+	claimed, err := repo.PreventRaceCondition(store.ClaimOptions{WorkerID: "w", Lease: time.Minute})
+	if err != nil || claimed == nil {
+		t.Fatalf("claim failed: %v %+v", err, claimed)
+	}
+	if err := repo.Delete(claimed.ID); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("expected ErrConflict deleting a running job, got %v", err)
+	}
+
+	// A pending job is cancelled on the way out and no longer listed.
+	other := "b"
+	if claimed.ID == "b" {
+		other = "a"
+	}
+	if err := repo.Delete(other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get(other); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected deleted job to be gone, got %v", err)
+	}
+	if err := repo.Delete(other); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound deleting twice, got %v", err)
+	}
+	var deleted job.Job
+	if err := repo.DB().Unscoped().First(&deleted, "id = ?", other).Error; err != nil {
+		t.Fatal(err)
+	}
+	if deleted.State != job.StateCancelled {
+		t.Fatalf("expected deleted job to be cancelled, got %s", deleted.State)
+	}
+}
+
+func TestRegisterMetricsReadsStore(t *testing.T) {
+	repo := newTestRepo(t)
+
+	for _, j := range []*job.Job{
+		{ID: "m1", Command: "false", MaxRetries: 3},
+		{ID: "m2", Command: "true", Queue: "emails"},
+		{ID: "m3", Command: "true", Queue: "emails"},
+	} {
+		if err := repo.Create(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// m1 fails once and then completes; m3 is deleted.
+	for _, state := range []job.JobState{job.StateFailed, job.StateCompleted} {
+		a := &job.Attempt{JobID: "m1", WorkerID: "w"}
+		if err := repo.StartAttempt(a); err != nil {
+			t.Fatal(err)
+		}
+		a.State = state
+		if err := repo.FinishAttempt(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Delete("m3"); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := metrics.NewRegistry()
+	store.RegisterMetrics(reg, repo)
+	var b strings.Builder
+	if err := reg.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`queuectl_jobs{queue="default",state="pending"} 1`,
+		`queuectl_jobs{queue="emails",state="pending"} 1`,
+		`queuectl_jobs{queue="emails",state="dead"} 0`,
+		`queuectl_jobs_enqueued_total{queue="emails"} 2`,
+		`queuectl_jobs_claimed_total{queue="default"} 2`,
+		`queuectl_jobs_failed_total{queue="default"} 1`,
+		`queuectl_jobs_completed_total{queue="default"} 1`,
+		`queuectl_job_retries_total{queue="default"} 1`,
+		`queuectl_jobs_claimed_total{queue="emails"} 0`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("missing %s in:\n%s", line, b.String())
+		}
+	}
+}
+
+func TestWatchJobsReportsEachChangeOnce(t *testing.T) {
+	// Stored times must not depend on the local zone of the process.
+	for _, loc := range []*time.Location{time.UTC, time.FixedZone("EST", -5*3600), time.FixedZone("JST", 9*3600)} {
+		t.Run(loc.String(), func(t *testing.T) {
+			local := time.Local
+			time.Local = loc
+			t.Cleanup(func() { time.Local = local })
+			testWatchJobs(t)
+		})
+	}
+}
+
+func testWatchJobs(t *testing.T) {
+	repo := newTestRepo(t)
+	start := time.Now().UTC().Add(-time.Second)
+
+	// More jobs than one page, all stamped with the same time.
+	var batch []*job.Job
+	for i := range 600 {
+		batch = append(batch, &job.Job{ID: fmt.Sprintf("w%03d", i), Command: "true"})
+	}
+	if _, err := repo.CreateBatch(batch, true); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan []job.Job)
+	go store.WatchJobs(ctx, repo, start, 10*time.Millisecond, func(jobs []job.Job) error {
+		select {
+		case events <- jobs:
+		case <-ctx.Done():
+		}
+		return nil
+	})
+	next := func(want int) []job.Job {
+		t.Helper()
+		deadline := time.After(5 * time.Second)
+		var got []job.Job
+		for len(got) < want {
+			select {
+			case jobs := <-events:
+				got = append(got, jobs...)
+			case <-deadline:
+				t.Fatalf("got %d changes, want %d", len(got), want)
+			}
+		}
+		return got
+	}
+
+	if got := next(600); len(got) != 600 {
+		t.Fatalf("got %d changes, want 600", len(got))
+	}
+	if _, err := repo.Cancel("w001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete("w002"); err != nil {
+		t.Fatal(err)
+	}
+	got := next(2)
+	if len(got) != 2 || got[0].ID != "w001" || got[0].State != job.StateCancelled || got[1].ID != "w002" || !got[1].DeletedAt.Valid {
+		t.Fatalf("unexpected changes: %+v", got)
+	}
+	// Later polls must not repeat them.
+	for range 3 {
+		if jobs := <-events; len(jobs) != 0 {
+			t.Fatalf("changes reported twice: %d jobs", len(jobs))
+		}
+	}
+}
+
+func TestSetPriorityAndRunNow(t *testing.T) {
+	repo := newTestRepo(t)
+
+	later := time.Now().UTC().Add(time.Hour)
+	if err := repo.Create(&job.Job{ID: "delayed", Command: "true", RunAt: &later}); err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := repo.PreventRaceCondition(store.ClaimOptions{WorkerID: "w", Lease: time.Minute}); j != nil {
+		t.Fatalf("delayed job claimed early: %+v", j)
+	}
+
+	j, err := repo.SetPriority("delayed", 7)
+	if err != nil || j.Priority != 7 {
+		t.Fatalf("SetPriority = %+v, %v", j, err)
+	}
+	if _, err := repo.RunNow("delayed"); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := repo.PreventRaceCondition(store.ClaimOptions{WorkerID: "w", Lease: time.Minute})
+	if err != nil || claimed == nil || claimed.ID != "delayed" || claimed.Priority != 7 {
+		t.Fatalf("expected the job to run now with priority 7, got %+v, %v", claimed, err)
+	}
+
+	// Claimed jobs keep their place.
+	if _, err := repo.SetPriority("delayed", 1); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("expected ErrConflict for a running job, got %v", err)
+	}
+	if _, err := repo.RunNow("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestFailureAfterCancelRequestIsCancelled(t *testing.T) {
+	for _, dead := range []bool{false, true} {
+		repo := newTestRepo(t)
+		if err := repo.Create(&job.Job{ID: "a", Command: "sleep 60", MaxRetries: 3}); err != nil {
+			t.Fatal(err)
+		}
+		claimed, err := repo.PreventRaceCondition(store.ClaimOptions{WorkerID: "w", Lease: time.Minute})
+		if err != nil || claimed == nil {
+			t.Fatalf("claim failed: %v %+v", err, claimed)
+		}
+		if _, err := repo.Cancel("a"); err != nil {
+			t.Fatal(err)
+		}
+
+		// The job exits non-zero before its next heartbeat sees the request.
+		if dead {
+			err = repo.MarkDead(claimed, "exit 1")
+		} else {
+			err = repo.Failed(claimed, "exit 1", time.Second)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := repo.Get("a")
+		if got.State != job.StateCancelled || got.RunAt != nil {
+			t.Fatalf("dead=%v: cancelled job ended %s (run_at %v), want cancelled", dead, got.State, got.RunAt)
+		}
+	}
+}
+
+func TestCancelRequestDoesNotOutliveItsRun(t *testing.T) {
+	repo := newTestRepo(t)
+	if err := repo.Create(&job.Job{ID: "a", Command: "true", MaxRetries: 1}); err != nil {
+		t.Fatal(err)
+	}
+	// A dead job still carrying a cancel request from its last run.
+	if err := repo.DB().Model(&job.Job{}).Where("id = ?", "a").
+		Updates(map[string]any{"state": job.StateDead, "cancel_requested": true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	retried, err := repo.RetryDead("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repo.Get("a"); retried.CancelRequested || got.CancelRequested {
+		t.Fatal("RetryDead kept the cancel request")
+	}
+
+	// Even if one survives, claiming starts a fresh run.
+	if err := repo.DB().Model(&job.Job{}).Where("id = ?", "a").Update("cancel_requested", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := repo.PreventRaceCondition(store.ClaimOptions{WorkerID: "w", Lease: time.Minute})
+	if err != nil || claimed == nil {
+		t.Fatalf("claim failed: %v %+v", err, claimed)
+	}
+	cancel, err := repo.Heartbeat("a", "w", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancel || claimed.CancelRequested {
+		t.Fatal("new run was told to cancel")
+	}
+}
+
+func TestCancelOfFinishedRunConflicts(t *testing.T) {
+	repo := newTestRepo(t)
+	if err := repo.Create(&job.Job{ID: "a", Command: "true"}); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := repo.PreventRaceCondition(store.ClaimOptions{WorkerID: "w", Lease: time.Minute})
+	if err != nil || claimed == nil {
+		t.Fatalf("claim failed: %v %+v", err, claimed)
+	}
+	loaded := *claimed
+	if err := repo.MarkCompleted(claimed); err != nil {
+		t.Fatal(err)
+	}
+	// The cancel read the job while it was still processing.
+	if err := store.CancelLoaded(repo, &loaded); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("expected ErrConflict cancelling a finished run, got %v", err)
+	}
+}
+
+func TestRetryOfRetriedJobConflicts(t *testing.T) {
+	repo := newTestRepo(t)
+	if err := repo.Create(&job.Job{ID: "a", Command: "true", Priority: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DB().Model(&job.Job{}).Where("id = ?", "a").Update("state", job.StateDead).Error; err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := repo.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.RetryDead("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.SetPriority("a", 9); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second retry read the job while it was still dead.
+	if err := store.RetryLoaded(repo, loaded); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("expected ErrConflict retrying a retried job, got %v", err)
+	}
+	if got, _ := repo.Get("a"); got.State != job.StatePending || got.Priority != 9 {
+		t.Fatalf("stale retry overwrote the job: %s priority %d", got.State, got.Priority)
+	}
+}
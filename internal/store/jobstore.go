@@ -0,0 +1,192 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"queuectl.backend/internal/job"
+)
+
+// ErrNotFound is returned when a job lookup matches nothing.
+var ErrNotFound = errors.New("job not found")
+
+var (
+	// ErrConflict matches (with errors.Is) errors about a job that is not in
+	// a state the operation applies to.
+	ErrConflict = errors.New("job state conflict")
+	// ErrInvalidFilter matches errors about a JobFilter that cannot be applied.
+	ErrInvalidFilter = errors.New("invalid filter")
+	// ErrInvalidJob matches errors about a job that cannot be stored as given,
+	// such as bad field values or unknown dependencies.
+	ErrInvalidJob = errors.New("invalid job")
+)
+
+// kindError keeps its own message while matching one of the errors above.
+type kindError struct {
+	kind error
+	msg  string
+}
+
+func (e *kindError) Error() string        { return e.msg }
+func (e *kindError) Is(target error) bool { return target == e.kind }
+
+func errorf(kind error, format string, args ...any) error {
+	return &kindError{kind: kind, msg: fmt.Sprintf(format, args...)}
+}
+
+// JobStore is the storage contract shared by the CLI, the web dashboard and
+// the workers. Every driver implements it; use Open to get the one matching
+// a DSN.
+type JobStore interface {
+	// Create inserts a new job. Jobs with DependsOn start blocked until their
+	// dependencies complete.
+	Create(j *job.Job) error
+	// CreateWorkflow atomically inserts a DAG of interdependent jobs.
+	CreateWorkflow(jobs []*job.Job) error
+	// CreateBatch inserts many jobs in one transaction, all-or-nothing when
+	// atomic is set, and returns the jobs it rejected.
+	CreateBatch(jobs []*job.Job, atomic bool) ([]BatchError, error)
+	// Get fetches a single job by ID, returning ErrNotFound if it does not exist.
+	Get(id string) (*job.Job, error)
+	// Update saves all fields of an existing job.
+	Update(j *job.Job) error
+
+	// FindPending returns the next runnable job without claiming it.
+	FindPending() (*job.Job, error)
+	// PreventRaceCondition atomically claims the next runnable job for a worker.
+	PreventRaceCondition(opts ClaimOptions) (*job.Job, error)
+	// Heartbeat renews the lease on a claimed job and reports whether its
+	// cancellation was requested.
+	Heartbeat(jobID, workerId string, lease time.Duration) (bool, error)
+	// ReapExpiredLeases sends jobs with expired leases back to the retry path.
+	ReapExpiredLeases(baseDelay time.Duration) (int, error)
+
+	// Processing marks a job as being processed.
+	Processing(j *job.Job) error
+	// MarkCompleted records a successful run.
+	MarkCompleted(j *job.Job) error
+	// MarkCancelled records that a running job was killed on request.
+	MarkCancelled(j *job.Job) error
+	// Failed records a failed run and schedules a retry or moves the job to the DLQ.
+	Failed(j *job.Job, errMsg string, baseDelay time.Duration) error
+	// MarkDead records a failed run that must not be retried.
+	MarkDead(j *job.Job, errMsg string) error
+
+	// StartAttempt opens a job_attempts row for a run that is starting.
+	StartAttempt(a *job.Attempt) error
+	// FinishAttempt records how an attempt ended.
+	FinishAttempt(a *job.Attempt) error
+	// ListAttempts returns a job's execution history, oldest first.
+	ListAttempts(jobID string) ([]job.Attempt, error)
+
+	// AppendLog stores a chunk of an attempt's output.
+	AppendLog(c *job.LogChunk) error
+	// DeleteLogs removes chunks dropped by the output size cap.
+	DeleteLogs(ids []uint) error
+	// ReadLogs returns a job's output chunks in write order.
+	ReadLogs(q LogQuery) ([]job.LogChunk, error)
+
+	// ListJobs lists jobs matching a filter.
+	ListJobs(f JobFilter) ([]job.Job, error)
+	// ChangedJobs pages through jobs by last update, deleted ones included.
+	ChangedJobs(since time.Time, afterID string, limit int) ([]job.Job, error)
+	// JobMetrics aggregates per-state counts and averages.
+	JobMetrics() (MetricsSummary, error)
+	// QueueMetrics breaks the per-state counts down by queue.
+	QueueMetrics() ([]QueueSummary, error)
+	// QueueCounters returns cumulative enqueue and attempt counts per queue.
+	QueueCounters() ([]QueueCounters, error)
+
+	// Cancel stops a single job; see JobRepo.Cancel.
+	Cancel(id string) (*job.Job, error)
+	// CancelAll cancels every unfinished job matching a filter.
+	CancelAll(f JobFilter) ([]job.Job, error)
+
+	// RetryDead moves a job from the DLQ back to pending with a fresh attempt count.
+	RetryDead(id string) (*job.Job, error)
+	// Delete removes a job that is not running, cancelling it first if needed.
+	Delete(id string) error
+	// SetPriority changes the priority of a job that is not claimed yet.
+	SetPriority(id string, priority int) (*job.Job, error)
+	// RunNow clears the run_at of a pending or failed job so it runs next.
+	RunNow(id string) (*job.Job, error)
+
+	// CreateInTx inserts a job inside tx with the checks of Create, for
+	// repositories that create jobs in their own transactions.
+	CreateInTx(tx *gorm.DB, j *job.Job) error
+
+	// DB exposes the underlying connection for repositories that share it,
+	// such as the config table.
+	DB() *gorm.DB
+	// Close releases the database connections. An in-memory database is
+	// gone once closed.
+	Close() error
+}
+
+var _ JobStore = (*JobRepo)(nil)
+
+// ClaimOptions describes who is claiming a job and what they may take.
+type ClaimOptions struct {
+	// WorkerID is recorded as the owner of the claimed job.
+	WorkerID string
+	// Lease is how long the claim stays valid without a heartbeat.
+	Lease time.Duration
+	// Queues restricts the claim to these queues; empty means every queue.
+	Queues []string
+	// AgingStep, when positive, raises a job's effective priority by one for
+	// every AgingStep it has been waiting. Zero means strict priority order.
+	AgingStep time.Duration
+}
+
+// JobFilter selects the jobs returned by ListJobs.
+type JobFilter struct {
+	// States keeps jobs in any of these states; empty means all states.
+	States []job.JobState
+	// Queue keeps jobs from a single queue; empty means all queues.
+	Queue string
+	// Since and Until keep jobs whose TimeField falls in [Since, Until);
+	// zero values leave that side open.
+	Since, Until time.Time
+	// TimeField is the timestamp Since and Until apply to (default TimeCreated).
+	TimeField TimeField
+	// CommandContains keeps jobs whose command includes this substring,
+	// ignoring case.
+	CommandContains string
+	// MinAttempts keeps jobs that have failed at least this many times.
+	MinAttempts int32
+	// Priority, when set, keeps jobs with exactly this priority.
+	Priority *int
+
+	// Sort orders the results (default SortPriority).
+	Sort JobSort
+	// NewestFirst orders jobs by descending creation time (within a priority
+	// under SortPriority).
+	NewestFirst bool
+	// Limit caps the number of jobs returned (default 100).
+	Limit int32
+	// Cursor continues a listing after the job it was made from; see
+	// JobCursor. It must come from a listing with the same Sort and NewestFirst.
+	Cursor string
+	// Offset skips that many matching jobs; prefer Cursor for deep pages.
+	Offset int32
+}
+
+// TimeField names a job timestamp for JobFilter.Since and Until.
+type TimeField string
+
+const (
+	TimeCreated TimeField = "created"
+	TimeUpdated TimeField = "updated"
+)
+
+// JobSort orders ListJobs results.
+type JobSort string
+
+const (
+	// SortPriority lists higher priorities first, then by creation time.
+	SortPriority JobSort = "priority"
+	// SortCreated lists by creation time only.
+	SortCreated JobSort = "created"
+)
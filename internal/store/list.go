@@ -0,0 +1,117 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"queuectl.backend/internal/job"
+)
+
+// cursor is the position of a job in a listing. It always carries the full
+// (priority, created_at, id) key so it works with every JobSort.
+type cursor struct {
+	Priority  int       `json:"p"`
+	CreatedAt time.Time `json:"c"`
+	ID        string    `json:"i"`
+}
+
+// JobCursor returns the JobFilter.Cursor that continues a listing after j.
+func JobCursor(j *job.Job) string {
+	b, _ := json.Marshal(cursor{Priority: j.Priority, CreatedAt: j.CreatedAt, ID: j.ID})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func parseCursor(s string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil || c.ID == "" {
+		return c, errorf(ErrInvalidFilter, "invalid cursor %q", s)
+	}
+	return c, nil
+}
+
+// applyFilter adds the WHERE conditions of f (everything but paging).
+func applyFilter(query *gorm.DB, f JobFilter) (*gorm.DB, error) {
+	if len(f.States) > 0 {
+		query = query.Where("state IN ?", f.States)
+	}
+	if f.Queue != "" {
+		query = query.Where("queue = ?", f.Queue)
+	}
+
+	column := "created_at"
+	switch f.TimeField {
+	case "", TimeCreated:
+	case TimeUpdated:
+		column = "updated_at"
+	default:
+		return nil, errorf(ErrInvalidFilter, "invalid time field %q (want %s or %s)", f.TimeField, TimeCreated, TimeUpdated)
+	}
+	if !f.Since.IsZero() {
+		query = query.Where(column+" >= ?", f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		query = query.Where(column+" < ?", f.Until.UTC())
+	}
+
+	if f.CommandContains != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(f.CommandContains)
+		// LIKE ignores case on SQLite but not on PostgreSQL; lowering both
+		// sides makes the filter match the same jobs on either.
+		query = query.Where(`LOWER(command) LIKE LOWER(?) ESCAPE '\'`, "%"+escaped+"%")
+	}
+	if f.MinAttempts > 0 {
+		query = query.Where("attempts >= ?", f.MinAttempts)
+	}
+	if f.Priority != nil {
+		query = query.Where("priority = ?", *f.Priority)
+	}
+	return query, nil
+}
+
+// sortKey is one column of a listing's order and of its keyset.
+type sortKey struct {
+	column string
+	desc   bool
+	value  any
+}
+
+// sortKeys returns the ORDER BY columns for f, with their values taken from c.
+// id is always last so the order is total and cursors never skip ties.
+func sortKeys(f JobFilter, c cursor) ([]sortKey, error) {
+	timeKeys := []sortKey{
+		{"created_at", f.NewestFirst, c.CreatedAt.UTC()},
+		{"id", f.NewestFirst, c.ID},
+	}
+	switch f.Sort {
+	case "", SortPriority:
+		return append([]sortKey{{"priority", true, c.Priority}}, timeKeys...), nil
+	case SortCreated:
+		return timeKeys, nil
+	default:
+		return nil, errorf(ErrInvalidFilter, "invalid sort %q (want %s or %s)", f.Sort, SortPriority, SortCreated)
+	}
+}
+
+// keysetCondition builds "row comes after the cursor" for keys, e.g.
+// priority < ? OR (priority = ? AND (created_at > ? OR (...))).
+func keysetCondition(keys []sortKey) (string, []any) {
+	k := keys[0]
+	op := ">"
+	if k.desc {
+		op = "<"
+	}
+	if len(keys) == 1 {
+		return fmt.Sprintf("%s %s ?", k.column, op), []any{k.value}
+	}
+	rest, args := keysetCondition(keys[1:])
+	cond := fmt.Sprintf("%s %s ? OR (%s = ? AND (%s))", k.column, op, k.column, rest)
+	return cond, append([]any{k.value, k.value}, args...)
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"queuectl.backend/internal/job"
+)
+
+// LogQuery selects log chunks of a job.
+type LogQuery struct {
+	JobID string
+	// Attempt limits the result to one attempt; 0 means all of them, or
+	// those from SinceAttempt on.
+	Attempt      int
+	SinceAttempt int
+	// Stream limits the result to job.StreamStdout or job.StreamStderr.
+	Stream string
+	// AfterID skips chunks up to and including this ID, for reading on
+	// from an earlier result.
+	AfterID uint
+	// Limit caps the number of chunks; 0 means no limit.
+	Limit int
+}
+
+// AppendLog stores one chunk of attempt output.
+func (r *JobRepo) AppendLog(c *job.LogChunk) error {
+	return r.db.Create(c).Error
+}
+
+// DeleteLogs removes chunks dropped to keep an attempt's log within its cap.
+func (r *JobRepo) DeleteLogs(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Delete(&job.LogChunk{}, ids).Error
+}
+
+// ReadLogs returns the chunks matching q in the order they were written.
+func (r *JobRepo) ReadLogs(q LogQuery) ([]job.LogChunk, error) {
+	tx := r.db.Where("job_id = ? AND id > ?", q.JobID, q.AfterID)
+	if q.Attempt > 0 {
+		tx = tx.Where("attempt = ?", q.Attempt)
+	} else if q.SinceAttempt > 0 {
+		tx = tx.Where("attempt >= ?", q.SinceAttempt)
+	}
+	if q.Stream != "" {
+		tx = tx.Where("stream = ?", q.Stream)
+	}
+	if q.Limit > 0 {
+		tx = tx.Limit(q.Limit)
+	}
+	var chunks []job.LogChunk
+	err := tx.Order("id ASC").Find(&chunks).Error
+	return chunks, err
+}
+
+// FollowLogs passes the chunks matching q to emit as workers write them,
+// checking for more every interval. It returns once everything is read and
+// the job is finished, or when q names an attempt, once that attempt ended.
+// Workers store an attempt's last output before they record its end, so
+// nothing is missed.
+func FollowLogs(ctx context.Context, s JobStore, q LogQuery, interval time.Duration, emit func([]job.LogChunk) error) error {
+	for {
+		// Look at the state first: chunks read afterwards are complete if
+		// the job had already ended.
+		done, err := logsDone(s, q)
+		if err != nil {
+			return err
+		}
+		for {
+			chunks, err := s.ReadLogs(LogQuery{JobID: q.JobID, Attempt: q.Attempt, SinceAttempt: q.SinceAttempt, Stream: q.Stream, AfterID: q.AfterID, Limit: 500})
+			if err != nil {
+				return err
+			}
+			if len(chunks) == 0 {
+				break
+			}
+			if err := emit(chunks); err != nil {
+				return err
+			}
+			q.AfterID = chunks[len(chunks)-1].ID
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// logsDone reports whether the output followed by q is complete.
+func logsDone(s JobStore, q LogQuery) (bool, error) {
+	j, err := s.Get(q.JobID)
+	if err != nil {
+		return false, err
+	}
+	if j.State.Finished() || q.Attempt == 0 {
+		return j.State.Finished(), nil
+	}
+	attempts, err := s.ListAttempts(q.JobID)
+	if err != nil {
+		return false, err
+	}
+	for _, a := range attempts {
+		if a.Number == q.Attempt {
+			return a.FinishedAt != nil, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/metrics"
+)
+
+// QueueCounters holds cumulative counts for one queue, taken from every job
+// ever enqueued (deleted ones included) and the attempt history. Unlike
+// QueueSummary they never go down, so they can be exported as counters.
+type QueueCounters struct {
+	Queue    string `json:"queue"`
+	Enqueued int64  `json:"enqueued"`
+	// Claimed counts attempts, i.e. every time a worker took a job.
+	Claimed int64 `json:"claimed"`
+	// Completed, Failed, Dead and Cancelled count attempts by the state they
+	// left their job in; Failed ones were scheduled for a retry.
+	Completed int64 `json:"completed"`
+	Failed    int64 `json:"failed"`
+	Dead      int64 `json:"dead"`
+	Cancelled int64 `json:"cancelled"`
+	// Retries counts attempts after a job's first.
+	Retries int64 `json:"retries"`
+}
+
+// QueueCounters returns the counters of every queue that ever had jobs,
+// sorted by queue name.
+func (r *JobRepo) QueueCounters() ([]QueueCounters, error) {
+	var enqueued []struct {
+		Queue string
+		Count int64
+	}
+	if err := r.db.Unscoped().Model(&job.Job{}).
+		Select("queue, COUNT(*) AS count").
+		Group("queue").
+		Order("queue").
+		Scan(&enqueued).Error; err != nil {
+		return nil, err
+	}
+
+	var attempts []struct {
+		Queue   string
+		State   job.JobState
+		Count   int64
+		Retries int64
+	}
+	if err := r.db.Model(&job.Attempt{}).
+		Select("jobs.queue AS queue, job_attempts.state AS state, COUNT(*) AS count, " +
+			"SUM(CASE WHEN job_attempts.number > 1 THEN 1 ELSE 0 END) AS retries").
+		Joins("JOIN jobs ON jobs.id = job_attempts.job_id").
+		Group("jobs.queue, job_attempts.state").
+		Scan(&attempts).Error; err != nil {
+		return nil, err
+	}
+
+	counters := make([]QueueCounters, len(enqueued))
+	index := make(map[string]*QueueCounters, len(enqueued))
+	for i, row := range enqueued {
+		counters[i] = QueueCounters{Queue: row.Queue, Enqueued: row.Count}
+		index[row.Queue] = &counters[i]
+	}
+	for _, row := range attempts {
+		c := index[row.Queue]
+		if c == nil {
+			continue // every attempt belongs to a job, so this cannot happen
+		}
+		c.Claimed += row.Count
+		c.Retries += row.Retries
+		switch row.State {
+		case job.StateCompleted:
+			c.Completed += row.Count
+		case job.StateFailed:
+			c.Failed += row.Count
+		case job.StateDead:
+			c.Dead += row.Count
+		case job.StateCancelled:
+			c.Cancelled += row.Count
+		}
+	}
+	return counters, nil
+}
+
+// RegisterMetrics adds metrics read from s on every scrape: current job
+// counts by queue and state, and the cumulative counters of QueueCounters.
+// They cover every worker and client sharing the database.
+func RegisterMetrics(reg *metrics.Registry, s JobStore) {
+	jobs := reg.Gauge("queuectl_jobs", "Jobs by queue and current state.", "queue", "state")
+	enqueued := reg.Counter("queuectl_jobs_enqueued_total", "Jobs enqueued, including since deleted ones.", "queue")
+	claimed := reg.Counter("queuectl_jobs_claimed_total", "Attempts started by workers.", "queue")
+	completed := reg.Counter("queuectl_jobs_completed_total", "Attempts that completed their job.", "queue")
+	failed := reg.Counter("queuectl_jobs_failed_total", "Attempts that failed and left their job to be retried.", "queue")
+	dead := reg.Counter("queuectl_jobs_dead_total", "Attempts that moved their job to the DLQ.", "queue")
+	cancelled := reg.Counter("queuectl_jobs_cancelled_total", "Attempts killed because their job was cancelled.", "queue")
+	retries := reg.Counter("queuectl_job_retries_total", "Attempts after a job's first.", "queue")
+
+	reg.OnScrape(func() error {
+		summaries, err := s.QueueMetrics()
+		if err != nil {
+			return err
+		}
+		counters, err := s.QueueCounters()
+		if err != nil {
+			return err
+		}
+
+		// Queues without jobs left disappear rather than report zeros forever.
+		jobs.Reset()
+		for _, q := range summaries {
+			for state, n := range map[job.JobState]int64{
+				job.StatePending:    q.Pending,
+				job.StateProcessing: q.Processing,
+				job.StateCompleted:  q.Completed,
+				job.StateFailed:     q.Failed,
+				job.StateDead:       q.Dead,
+				job.StateBlocked:    q.Blocked,
+				job.StateCancelled:  q.Cancelled,
+			} {
+				jobs.Set(float64(n), q.Queue, string(state))
+			}
+		}
+		for _, c := range counters {
+			enqueued.Set(float64(c.Enqueued), c.Queue)
+			claimed.Set(float64(c.Claimed), c.Queue)
+			completed.Set(float64(c.Completed), c.Queue)
+			failed.Set(float64(c.Failed), c.Queue)
+			dead.Set(float64(c.Dead), c.Queue)
+			cancelled.Set(float64(c.Cancelled), c.Queue)
+			retries.Set(float64(c.Retries), c.Queue)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,10 @@
+package store
+
+import "gorm.io/gorm"
+
+// NewPostgresRepo creates a repository for a PostgreSQL database. Claims use
+// SELECT ... FOR UPDATE SKIP LOCKED so workers on many hosts can poll the same
+// table without blocking on, or double-claiming, each other's rows.
+func NewPostgresRepo(db *gorm.DB) *JobRepo {
+	return &JobRepo{db: db, skipLocked: true}
+}
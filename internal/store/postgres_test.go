@@ -0,0 +1,85 @@
+package store_test
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/store"
+)
+
+// pgDSNEnv names a PostgreSQL database to run the store tests against
+// instead of SQLite, e.g. postgres://localhost/queuectl_test. The tests
+// empty its tables, so never point it at a real queue.
+const pgDSNEnv = "QUEUECTL_TEST_PG"
+
+// openTestPostgres opens the database in $QUEUECTL_TEST_PG with empty tables.
+func openTestPostgres(t *testing.T, dsn string) store.JobStore {
+	t.Helper()
+	repo, err := store.Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	if err := repo.DB().Exec("TRUNCATE jobs, job_dependencies, job_attempts, job_logs, configs, schedules").Error; err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestConcurrentClaimsTakeEachJobOnce(t *testing.T) {
+	repo := newTestRepo(t)
+	const jobs, workers = 40, 8
+	for i := range jobs {
+		if err := repo.Create(&job.Job{ID: fmt.Sprintf("c%02d", i), Command: "true"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	claimed := make(chan string, jobs)
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				j, err := repo.PreventRaceCondition(store.ClaimOptions{WorkerID: fmt.Sprintf("w%d", w), Lease: time.Minute})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if j == nil {
+					return
+				}
+				claimed <- j.ID
+			}
+		}()
+	}
+	wg.Wait()
+	close(claimed)
+
+	seen := map[string]bool{}
+	for id := range claimed {
+		if seen[id] {
+			t.Fatalf("job %s claimed twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != jobs {
+		t.Fatalf("claimed %d jobs, want %d", len(seen), jobs)
+	}
+}
+
+func TestPostgresIsSelectedByDSN(t *testing.T) {
+	dsn := os.Getenv(pgDSNEnv)
+	if dsn == "" {
+		t.Skipf("set %s to run the store tests against PostgreSQL", pgDSNEnv)
+	}
+	repo := openTestPostgres(t, dsn)
+	if name := repo.DB().Dialector.Name(); name != "postgres" {
+		t.Fatalf("dialect = %s, want postgres", name)
+	}
+}
@@ -3,25 +3,132 @@ package store
 import (
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"queuectl.backend/internal/config"
 	"queuectl.backend/internal/job"
+	"queuectl.backend/internal/schedule"
 
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
-func InitDB() (*gorm.DB, error) {
-	dsn := "queue.db?_journal_mode=WAL&_busy_timeout=5000"
-	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+// MemoryDSN selects a private in-memory SQLite database that lives only as
+// long as the process. Nothing is ever written to disk.
+const MemoryDSN = ":memory:"
+
+// DefaultPath returns the SQLite file used when no database is configured:
+// $XDG_DATA_HOME/queuectl/queue.db, falling back to ~/.local/share. Using a
+// fixed location means every command agrees on one queue regardless of the
+// directory it is run from.
+func DefaultPath() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "queue.db"
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "queuectl", "queue.db")
+}
+
+// dbLogger reports slow queries and real errors on stderr, keeping stdout for
+// command output such as --output json. Missing rows are expected, not errors.
+var dbLogger = logger.New(log.New(os.Stderr, "", log.LstdFlags), logger.Config{
+	SlowThreshold:             200 * time.Millisecond,
+	LogLevel:                  logger.Warn,
+	IgnoreRecordNotFoundError: true,
+})
+
+// memoryDBs numbers in-memory databases so every Open(MemoryDSN) is isolated.
+var memoryDBs atomic.Int64
+
+// Open connects to the database named by dsn and returns the job store for
+// its driver. DSNs starting with postgres:// or postgresql:// select the
+// PostgreSQL driver, MemoryDSN an in-memory database, and anything else is
+// treated as a SQLite database file.
+func Open(dsn string) (JobStore, error) {
+	db, err := InitDB(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if isPostgres(dsn) {
+		return NewPostgresRepo(db), nil
+	}
+	return NewJobRepo(db), nil
+}
+
+// OpenMemory returns a job store backed by a fresh in-memory database, for
+// tests, benchmarks and ephemeral runs.
+func OpenMemory() (JobStore, error) {
+	return Open(MemoryDSN)
+}
+
+// InitDB opens the database named by dsn and migrates the schema.
+func InitDB(dsn string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch {
+	case isPostgres(dsn):
+		dialector = postgres.Open(dsn)
+	case dsn == MemoryDSN:
+		// A named shared-cache database keeps the data visible to every pooled
+		// connection; the name keeps separate stores from seeing each other.
+		name := fmt.Sprintf("file:queuectl-mem-%d?mode=memory&cache=shared&_busy_timeout=5000", memoryDBs.Add(1))
+		dialector = sqlite.Open(name)
+	default:
+		path := sqlitePath(dsn)
+		if dir := filepath.Dir(path); dir != "." {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return nil, fmt.Errorf("database directory: %w", err)
+			}
+		}
+		dialector = sqlite.Open(sqliteDSN(dsn))
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		TranslateError: true,
+		Logger:         dbLogger,
+		// Stamp rows in UTC: SQLite compares times as text, so a local
+		// offset would sort created_at and updated_at in the wrong order.
+		NowFunc: func() time.Time { return time.Now().UTC() },
+	})
 	if err != nil {
 		return nil, fmt.Errorf("database opening failure: %w", err)
 	}
 
-	if err := db.AutoMigrate(&job.Job{}, &config.Config{}); err != nil {
+	if dsn == MemoryDSN {
+		// Shared-cache tables lock per connection without honoring busy_timeout,
+		// and the database vanishes once its last connection closes; a single
+		// long-lived connection avoids both.
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("database opening failure: %w", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxIdleTime(0)
+		sqlDB.SetConnMaxLifetime(0)
+	}
+
+	if err := db.AutoMigrate(&job.Job{}, &job.Dependency{}, &job.Attempt{}, &job.LogChunk{}, &config.Config{}, &schedule.Schedule{}); err != nil {
 		return nil, fmt.Errorf("migration failure: %w", err)
 	}
 
+	if isPostgres(dsn) {
+		log.Printf("Database connected and ready (PostgreSQL)...")
+		return db, nil
+	}
+	if dsn == MemoryDSN {
+		log.Printf("Database connected and ready (in-memory, nothing is persisted)...")
+		return db, nil
+	}
+
 	if err := db.Exec("PRAGMA journal_mode=WAL;").Error; err != nil {
 		log.Printf("warning: failed to enable WAL mode: %v", err)
 	}
@@ -29,6 +136,28 @@ func InitDB() (*gorm.DB, error) {
 		log.Printf("warning: failed to set busy timeout: %v", err)
 	}
 
-	log.Printf("Database connected and ready (WAL mode, 5s busy timeout)...")
+	log.Printf("Database %s connected and ready (WAL mode, 5s busy timeout)...", sqlitePath(dsn))
 	return db, nil
 }
+
+func isPostgres(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// sqlitePath strips the scheme and options from a SQLite DSN, leaving the file path.
+func sqlitePath(dsn string) string {
+	dsn = strings.TrimPrefix(dsn, "sqlite://")
+	dsn = strings.TrimPrefix(dsn, "file:")
+	path, _, _ := strings.Cut(dsn, "?")
+	return path
+}
+
+// sqliteDSN turns a plain file path into a DSN with WAL and a busy timeout,
+// leaving DSNs that already carry their own options untouched.
+func sqliteDSN(dsn string) string {
+	dsn = strings.TrimPrefix(dsn, "sqlite://")
+	if strings.Contains(dsn, "?") {
+		return dsn
+	}
+	return dsn + "?_journal_mode=WAL&_busy_timeout=5000"
+}
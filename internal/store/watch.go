@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"queuectl.backend/internal/job"
+)
+
+// watchSlack is how far back each WatchJobs poll looks before the latest
+// change it saw, to catch changes that committed late or were stamped by a
+// host with a slightly different clock.
+const watchSlack = 2 * time.Second
+
+// watchPage is the number of jobs WatchJobs reads per query.
+const watchPage = 500
+
+// ChangedJobs returns up to limit jobs in (updated_at, id) order, starting
+// after the job with update time since and ID afterID; an empty afterID
+// starts at since. Times are compared in UTC, as they are stored. Deleted
+// jobs are included with DeletedAt set: deleting a job counts as updating it.
+func (r *JobRepo) ChangedJobs(since time.Time, afterID string, limit int) ([]job.Job, error) {
+	var jobs []job.Job
+	err := r.db.Unscoped().
+		Where("updated_at > ? OR (updated_at = ? AND id > ?)", since.UTC(), since.UTC(), afterID).
+		Order("updated_at ASC, id ASC").
+		Limit(limit).
+		Find(&jobs).Error
+	return jobs, err
+}
+
+// WatchJobs polls s every interval for jobs that changed after since and
+// passes them to emit, each change once, until ctx is done or emit fails.
+// emit is called after every poll, with no jobs when nothing changed, so
+// callers can do periodic work such as keep-alives.
+func WatchJobs(ctx context.Context, s JobStore, since time.Time, interval time.Duration, emit func([]job.Job) error) error {
+	// seen holds the update time last emitted per job, for the jobs that the
+	// slack makes every poll read again.
+	seen := map[string]time.Time{}
+	latest := since
+	for {
+		from, afterID := latest.Add(-watchSlack), ""
+		var changed []job.Job
+		for {
+			jobs, err := s.ChangedJobs(from, afterID, watchPage)
+			if err != nil {
+				return err
+			}
+			for _, j := range jobs {
+				if !j.UpdatedAt.After(since) || seen[j.ID].Equal(j.UpdatedAt) {
+					continue
+				}
+				seen[j.ID] = j.UpdatedAt
+				changed = append(changed, j)
+				if j.UpdatedAt.After(latest) {
+					latest = j.UpdatedAt
+				}
+			}
+			if len(jobs) < watchPage {
+				break
+			}
+			last := jobs[len(jobs)-1]
+			from, afterID = last.UpdatedAt, last.ID
+		}
+		for id, at := range seen {
+			if at.Before(latest.Add(-watchSlack)) {
+				delete(seen, id)
+			}
+		}
+		if err := emit(changed); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
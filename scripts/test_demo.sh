@@ -7,6 +7,9 @@ echo "  Running Queuectl End-to-End Demo Test"
 echo "=========================================="
 echo ""
 
+# Keep the demo queue in the working directory instead of the per-user default.
+export QUEUECTL_DB=queue.db
+
 # --- Clean slate ---
 if [ -f queue.db ]; then
   echo "[Cleanup] Removing existing queue.db..."